@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/DownloadableFox/twotto-v2/internal/api"
 	"github.com/DownloadableFox/twotto-v2/internal/config"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/core"
+	"github.com/DownloadableFox/twotto-v2/internal/modules/core/middlewares"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/yiff"
+	"github.com/DownloadableFox/twotto-v2/internal/storage"
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -31,6 +35,33 @@ func main() {
 		return
 	}
 
+	// Open the embedded database
+	db, err := storage.Open(config.DatabasePath())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open database!")
+	}
+	defer db.Close()
+
+	reports, err := storage.NewErrorReportStore(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create error report store!")
+	}
+
+	history, err := storage.NewTaskHistoryStore(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create task history store!")
+	}
+
+	jobs, err := storage.NewJobStore(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create job store!")
+	}
+
+	commandGuilds, err := storage.NewCommandGuildStore(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create command guild store!")
+	}
+
 	// Initialize the bot with the loaded config
 	client, err := discordgo.New("Bot " + config.BotToken)
 	if err != nil {
@@ -42,17 +73,54 @@ func main() {
 	client.StateEnabled = true
 	client.Compress = true
 
+	// Root context cancelled once the bot is asked to stop
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Errors are stored and forwarded to the developers if configured
+	sinks := api.ErrorSinks{reports}
+	if config.Errors.Webhook != "" {
+		sink, err := api.NewWebhookErrorSink(client, config.Errors.Webhook)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create error webhook!")
+		}
+
+		sinks = append(sinks, sink)
+	} else if config.Errors.ChannelID != "" {
+		sinks = append(sinks, api.NewChannelErrorSink(client, config.Errors.ChannelID))
+	}
+	ctx = api.WithErrorSink(ctx, sinks)
+
 	// Command Manager
 	moduleManager := api.NewModuleManager()
-	commandManager := api.NewCommandManager()
-	eventManager := api.NewEventManager()
-	taskManager := api.NewTaskManager()
+	commandManager := api.NewCommandManager(ctx)
+	commandManager.DryRun = config.Commands.DryRun
+	commandManager.DevGuildID = config.Commands.DevGuildID
+	commandManager.Timeout = time.Duration(config.Timeouts.Commands) * time.Second
+	commandManager.Guilds = commandGuilds
+	eventManager := api.NewEventManager(ctx)
+	eventManager.Timeout = time.Duration(config.Timeouts.Events) * time.Second
+	taskManager := api.NewTaskManager(ctx)
+	taskManager.Timeout = time.Duration(config.Timeouts.Tasks) * time.Second
+	taskManager.History = history
+	taskManager.Jobs = jobs
+
+	// Middleware shared by every module, it wraps the middleware of the modules
+	recoverer := middlewares.NewRecoverMiddleware(log.Logger, config.Contact())
+	moduleManager.CommandMiddleware = []api.CommandMiddleware{
+		recoverer,
+		middlewares.NewDeferMiddleware(log.Logger, time.Duration(config.Commands.DeferAfter)*time.Millisecond),
+	}
+	moduleManager.ComponentMiddleware = []api.ComponentMiddleware{recoverer}
+	moduleManager.EventMiddleware = []api.AnyEventMiddleware{middlewares.NewEventLogMiddleware(log.Logger)}
+	moduleManager.TaskMiddleware = []api.TaskMiddleware{middlewares.NewTaskLogMiddleware(log.Logger)}
+	taskManager.JobMiddleware = moduleManager.TaskMiddleware
 
 	// Register the core module
 	log.Info().Msg("Registering modules ...")
 	if err := moduleManager.RegisterModules(
-		core.NewCoreModule(log.Logger),
-		yiff.NewYiffModule(log.Logger),
+		core.NewCoreModule(log.Logger, config, reports, taskManager, stop),
+		yiff.NewYiffModule(log.Logger, config),
 	); err != nil {
 		log.Fatal().Err(err).Msg("Failed to register module!")
 	}
@@ -82,7 +150,7 @@ func main() {
 	}
 
 	log.Info().Msg("Bot is set and running!")
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-	<-sc
+	<-ctx.Done()
+
+	log.Info().Msg("Shutting down ...")
 }
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Maximum amount of choices Discord accepts in an autocomplete result
+const MaxAutocompleteChoices = 25
+
+// AutocompleteCommand is implemented by commands that have options with
+// autocomplete enabled. The returned choices are sent back to Discord by the
+// command manager, autocomplete requests do not go through the middleware.
+type AutocompleteCommand interface {
+	Command
+	Autocomplete(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error)
+}
+
+// Returns the option currently being typed by the user, looking into nested
+// subcommands and subcommand groups.
+func FocusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+
+		if focused := FocusedOption(option.Options); focused != nil {
+			return focused
+		}
+	}
+
+	return nil
+}
+
+func respondAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, choices []*discordgo.ApplicationCommandOptionChoice) error {
+	if len(choices) > MaxAutocompleteChoices {
+		choices = choices[:MaxAutocompleteChoices]
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog/log"
@@ -16,6 +20,10 @@ type CommandMiddlewareFunc func(command Command, next CommandExecuteFunc) Comman
 type CommandStack struct {
 	Command    Command
 	Middleware []CommandMiddleware
+	Scope      CommandScope
+
+	// Overrides the default execution timeout of the manager when positive
+	Timeout time.Duration
 }
 
 type Command interface {
@@ -30,139 +38,509 @@ type CommandMiddleware interface {
 type CommandManager interface {
 	PublishCommands(session *discordgo.Session) error
 	RegisterStack(stack CommandStack) error
+	RegisterComponentStack(stack ComponentStack) error
+}
+
+// Key used to look up commands in the dispatch table, Discord allows
+// commands of different types to share the same name.
+type CommandKey struct {
+	Type discordgo.ApplicationCommandType
+	Name string
+}
+
+type CompiledCommand struct {
+	Command Command
+	Execute CommandExecuteFunc
+
+	// Guilds the command is published to, empty for global commands
+	GuildIDs []string
+	Timeout  time.Duration
+}
+
+// Autocomplete results are discarded by Discord after 3 seconds
+const AutocompleteTimeout = 3 * time.Second
+
+// CommandGuildStore remembers the guilds commands were published to, so
+// guilds no longer in any scope can be cleaned up after a restart.
+type CommandGuildStore interface {
+	PublishedGuilds() ([]string, error)
+	SavePublishedGuilds(guildIDs []string) error
 }
 
 type CommandManagerImpl struct {
-	commands map[string]CommandStack
+	// When enabled the command sync only logs the planned changes
+	DryRun bool
+
+	// Guild where commands scoped to development are published
+	DevGuildID string
+
+	// Default execution timeout of commands and components, none when zero
+	Timeout time.Duration
+
+	// Where the published guilds are kept between restarts, only the
+	// previous publish of this run is known when nil
+	Guilds CommandGuildStore
+
+	// Context every invocation is derived from
+	root context.Context
+
+	commands   map[CommandKey]CommandStack
+	components map[string]ComponentStack
+
+	// Dispatch tables built when publishing
+	lock               sync.RWMutex
+	compiledCommands   map[CommandKey]CompiledCommand
+	compiledComponents map[string]ComponentExecuteFunc
+	removeHandler      func()
+
+	// Guilds commands were published to by the previous publish, only used
+	// when no store is set
+	publishedGuilds []string
 }
 
-func NewCommandManager() *CommandManagerImpl {
+func NewCommandManager(root context.Context) *CommandManagerImpl {
 	return &CommandManagerImpl{
-		commands: make(map[string]CommandStack),
+		root:               root,
+		commands:           make(map[CommandKey]CommandStack),
+		components:         make(map[string]ComponentStack),
+		compiledCommands:   make(map[CommandKey]CompiledCommand),
+		compiledComponents: make(map[string]ComponentExecuteFunc),
 	}
 }
 
-func (cm *CommandManagerImpl) PublishCommands(session *discordgo.Session) error {
-	type GeneratedCommand struct {
-		data    discordgo.ApplicationCommand
-		execute CommandExecuteFunc
+// Returns the dispatch key of a command, chat input is assumed when no type is set.
+func NewCommandKey(data discordgo.ApplicationCommand) CommandKey {
+	if data.Type == 0 {
+		data.Type = discordgo.ChatApplicationCommand
 	}
 
-	var publishChannel = make(chan GeneratedCommand, len(cm.commands))
+	return CommandKey{Type: data.Type, Name: data.Name}
+}
 
-	// Flush commands before publishing new ones
-	if err := cm.FlushCommands(session); err != nil {
-		return fmt.Errorf("failed to flush commands: %w", err)
+func (cm *CommandManagerImpl) PublishCommands(session *discordgo.Session) error {
+	// Global commands are published under an empty guild ID
+	published := map[string][]*discordgo.ApplicationCommand{"": {}}
+	if cm.DevGuildID != "" {
+		published[cm.DevGuildID] = []*discordgo.ApplicationCommand{}
 	}
 
-	go func() {
-		defer close(publishChannel)
+	compiled := make(map[CommandKey]CompiledCommand, len(cm.commands))
+	for key, stack := range cm.commands {
+		data := stack.Command.Data()
 
-		for _, stack := range cm.commands {
-			data := stack.Command.Data()
+		guildIDs, err := stack.Scope.GuildIDs(cm.DevGuildID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Skipping command %q", data.Name)
+			continue
+		}
 
-			// Register the command with the middleware
-			next := func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
-				return stack.Command.Execute(c, s, i)
-			}
+		if len(guildIDs) == 0 {
+			published[""] = append(published[""], &data)
+		}
 
-			// Execute the middleware in reverse order
-			// to ensure the first middleware is executed last
-			for i := len(stack.Middleware) - 1; i >= 0; i-- {
-				mw := stack.Middleware[i]
-				next = mw.Handle(stack.Command, next)
-			}
+		for _, guildID := range guildIDs {
+			published[guildID] = append(published[guildID], &data)
+		}
 
-			// Send the command to the publish channel
-			publishChannel <- GeneratedCommand{
-				data:    data,
-				execute: next,
-			}
+		timeout := stack.Timeout
+		if timeout <= 0 {
+			timeout = cm.Timeout
 		}
-	}()
 
-	// TODO: Multi-thread this to allow for multiple commands to be published at once
-	// Wait for all commands to be published
-	for cmd := range publishChannel {
-		data := cmd.data
-		next := cmd.execute
+		compiled[key] = CompiledCommand{
+			Command:  stack.Command,
+			Execute:  stack.Compile(),
+			GuildIDs: guildIDs,
+			Timeout:  timeout,
+		}
+	}
 
-		// Register the command with the Discord API
-		if _, err := session.ApplicationCommandCreate(session.State.User.ID, "", &data); err != nil {
-			return err
+	// Guilds dropped from every scope (or an old dev guild) still hold the
+	// commands published there before, syncing an empty list removes them
+	for _, guildID := range cm.previousGuilds() {
+		if _, exists := published[guildID]; !exists {
+			published[guildID] = []*discordgo.ApplicationCommand{}
 		}
+	}
 
-		// Register the command handler with the session
-		session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			if i.ApplicationCommandData().Name != data.Name {
-				return
+	var guildIDs, failed []string
+
+	// Register the commands with the Discord API, one sync per scope
+	for guildID, local := range published {
+		// Keep the published order stable between runs
+		slices.SortFunc(local, func(a, b *discordgo.ApplicationCommand) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		if err := cm.SyncCommands(session, guildID, local); err != nil {
+			// A failed cleanup (e.g. a guild the bot was removed from) shouldn't block publishing
+			if len(local) == 0 {
+				log.Warn().Err(err).Msgf("Failed to clear stale commands (%s)", scopeName(guildID))
+				failed = append(failed, guildID)
+				continue
 			}
 
-			defer func() {
-				if rec := recover(); rec != nil {
-					// Get stacktrace
-					stacktrace := make([]byte, 4096)
-					count := runtime.Stack(stacktrace, false)
+			return fmt.Errorf("failed to sync commands for %s: %w", scopeName(guildID), err)
+		}
 
-					log.Error().Any("panic", rec).Msg("Recovered from panic in command execution")
-					log.Debug().Msg("Panic stack trace: \n" + string(stacktrace[:count]))
-				}
-			}()
+		if guildID != "" && len(local) > 0 {
+			guildIDs = append(guildIDs, guildID)
+		}
+	}
 
-			if err := next(context.Background(), s, i); err != nil {
-				log.Error().Err(err).Msg("Unhandled error in command execution")
-			}
-		})
+	// Failed cleanups are kept to be retried on the next publish
+	if !cm.DryRun {
+		cm.savePublishedGuilds(append(guildIDs, failed...))
+	}
+
+	cm.lock.Lock()
+	cm.compiledCommands = compiled
+	cm.compiledComponents = cm.CompileComponents()
+	cm.lock.Unlock()
+
+	// Register the interaction router once, further publishes only swap the tables
+	if cm.removeHandler == nil {
+		cm.removeHandler = session.AddHandler(cm.HandleInteraction)
 	}
 
 	return nil
 }
 
-func (cm *CommandManagerImpl) FlushCommands(session *discordgo.Session) error {
-	var flushChannel = make(chan string, len(cm.commands))
+// Returns the guilds commands were published to last time.
+func (cm *CommandManagerImpl) previousGuilds() []string {
+	if cm.Guilds == nil {
+		return cm.publishedGuilds
+	}
 
-	go func() {
-		defer close(flushChannel)
+	guildIDs, err := cm.Guilds.PublishedGuilds()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read the guilds commands were published to")
+	}
 
-		// Get currently registered commands
-		registeredCommands, err := session.ApplicationCommands(session.State.User.ID, "")
-		if err != nil {
-			log.Warn().Err(err).Msg("Failed to fetch registered commands")
-			return
+	return guildIDs
+}
+
+func (cm *CommandManagerImpl) savePublishedGuilds(guildIDs []string) {
+	slices.Sort(guildIDs)
+
+	if cm.Guilds == nil {
+		cm.publishedGuilds = guildIDs
+		return
+	}
+
+	if err := cm.Guilds.SavePublishedGuilds(guildIDs); err != nil {
+		log.Warn().Err(err).Msg("Failed to save the guilds commands were published to")
+	}
+}
+
+// Compares the local commands with the ones registered on Discord for the
+// given guild (or globally when empty) and pushes the differences in a single
+// bulk overwrite. In dry-run mode the planned changes are only logged.
+func (cm *CommandManagerImpl) SyncCommands(session *discordgo.Session, guildID string, local []*discordgo.ApplicationCommand) error {
+	scope := scopeName(guildID)
+
+	// Fetched with_localizations=true (discordgo always asks for them), without
+	// them every localized command would look changed on each sync
+	remote, err := session.ApplicationCommands(session.State.User.ID, guildID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch registered commands: %w", err)
+	}
+
+	diff := DiffCommands(local, remote)
+	for _, cmd := range diff.Create {
+		log.Info().Msgf("Command %q will be created (%s)", cmd.Name, scope)
+	}
+
+	for _, cmd := range diff.Update {
+		log.Info().Msgf("Command %q will be updated (%s)", cmd.Name, scope)
+	}
+
+	for _, cmd := range diff.Delete {
+		log.Info().Msgf("Command %q will be deleted (%s)", cmd.Name, scope)
+	}
+
+	if diff.Empty() {
+		log.Info().Msgf("All %d commands are up to date (%s), skipping sync", len(diff.Unchanged), scope)
+		return nil
+	}
+
+	if cm.DryRun {
+		log.Warn().Msgf("Dry run enabled, not applying %d create, %d update and %d delete (%s)", len(diff.Create), len(diff.Update), len(diff.Delete), scope)
+		return nil
+	}
+
+	if _, err := session.ApplicationCommandBulkOverwrite(session.State.User.ID, guildID, local); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Synced commands (%d created, %d updated, %d deleted) (%s)", len(diff.Create), len(diff.Update), len(diff.Delete), scope)
+	return nil
+}
+
+func (cm *CommandManagerImpl) CompileComponents() map[string]ComponentExecuteFunc {
+	var compiled = make(map[string]ComponentExecuteFunc, len(cm.components))
+
+	for prefix, stack := range cm.components {
+		// Register the component with the middleware
+		next := func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+			return stack.Component.Execute(c, s, i)
 		}
 
-		// Check if the command is registered
-		for _, cmd := range registeredCommands {
-			if _, exists := cm.commands[cmd.Name]; !exists {
-				flushChannel <- cmd.ID
-			}
+		// Execute the middleware in reverse order
+		// to ensure the first middleware is executed last
+		for i := len(stack.Middleware) - 1; i >= 0; i-- {
+			mw := stack.Middleware[i]
+			next = mw.HandleComponent(stack.Component, next)
+		}
+
+		compiled[prefix] = next
+	}
+
+	return compiled
+}
+
+// Single entry point for every interaction received by the session.
+func (cm *CommandManagerImpl) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			// Get stacktrace
+			stacktrace := make([]byte, 4096)
+			count := runtime.Stack(stacktrace, false)
+
+			log.Error().Any("panic", rec).Msg("Recovered from panic in interaction execution")
+			log.Debug().Msg("Panic stack trace: \n" + string(stacktrace[:count]))
 		}
 	}()
 
-	// Unregister the commands
-	for cmdID := range flushChannel {
-		if err := session.ApplicationCommandDelete(session.State.User.ID, "", cmdID); err != nil {
-			return fmt.Errorf("failed to delete command %q: %w", cmdID, err)
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		cm.HandleCommand(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		cm.HandleAutocomplete(s, i)
+	case discordgo.InteractionMessageComponent, discordgo.InteractionModalSubmit:
+		cm.HandleComponent(s, i)
+	}
+}
+
+func (cm *CommandManagerImpl) HandleCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	cm.lock.RLock()
+	command, exists := cm.compiledCommands[CommandKey{Type: data.CommandType, Name: data.Name}]
+	cm.lock.RUnlock()
+
+	// Stale registrations in guilds outside the command scope are treated as unknown
+	if exists && len(command.GuildIDs) > 0 && !slices.Contains(command.GuildIDs, i.GuildID) {
+		exists = false
+	}
+
+	if !exists {
+		log.Warn().Msgf("Received interaction for unknown command %q", data.Name)
+
+		if err := respondUnknown(s, i, "This command is no longer available. It might have been removed or renamed."); err != nil {
+			log.Warn().Err(err).Msg("Failed to reply to unknown command")
 		}
+
+		return
 	}
 
-	return nil
+	c, cancel := NewInvocationContext(cm.root, Invocation{
+		Kind:    "command",
+		Name:    data.Name,
+		GuildID: i.GuildID,
+		UserID:  InteractionUser(i).ID,
+	}, command.Timeout)
+	defer cancel()
+
+	c = WithResponder(c, NewResponder(s, i))
+	if err := command.Execute(c, s, i); err != nil {
+		Logger(c).Error().Err(err).Msg("Unhandled error in command execution")
+	}
+}
+
+func (cm *CommandManagerImpl) HandleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	cm.lock.RLock()
+	command, exists := cm.compiledCommands[CommandKey{Type: data.CommandType, Name: data.Name}]
+	cm.lock.RUnlock()
+
+	var choices = []*discordgo.ApplicationCommandOptionChoice{}
+
+	// Autocomplete requests are answered directly by the command
+	if autocomplete, ok := command.Command.(AutocompleteCommand); !exists {
+		log.Warn().Msgf("Received autocomplete request for unknown command %q", data.Name)
+	} else if !ok {
+		log.Warn().Msgf("Command %q received an autocomplete request but does not implement it", data.Name)
+	} else {
+		c, cancel := NewInvocationContext(cm.root, Invocation{
+			Kind:    "autocomplete",
+			Name:    data.Name,
+			GuildID: i.GuildID,
+			UserID:  InteractionUser(i).ID,
+		}, AutocompleteTimeout)
+		defer cancel()
+
+		result, err := autocomplete.Autocomplete(c, s, i)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to autocomplete command %q", data.Name)
+		} else {
+			choices = result
+		}
+	}
+
+	if err := respondAutocomplete(s, i, choices); err != nil {
+		log.Warn().Err(err).Msgf("Failed to send autocomplete choices for command %q", data.Name)
+	}
+}
+
+func (cm *CommandManagerImpl) HandleComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID, err := InteractionCustomID(i)
+	if err != nil {
+		return
+	}
+
+	cm.lock.RLock()
+
+	// Find the component with the longest matching prefix
+	var next ComponentExecuteFunc
+	var prefix string
+	for prefix = customID; prefix != ""; {
+		if execute, exists := cm.compiledComponents[prefix]; exists {
+			next = execute
+			break
+		}
+
+		index := strings.LastIndex(prefix, ComponentIDSeparator)
+		if index < 0 {
+			break
+		}
+
+		prefix = prefix[:index]
+	}
+
+	cm.lock.RUnlock()
+
+	if next == nil {
+		log.Warn().Msgf("No component registered for custom id %q", customID)
+
+		if err := respondUnknown(s, i, "This interaction is no longer available. Try running the command again."); err != nil {
+			log.Warn().Err(err).Msg("Failed to reply to unknown component")
+		}
+
+		return
+	}
+
+	c, cancel := NewInvocationContext(cm.root, Invocation{
+		Kind:    "component",
+		Name:    prefix,
+		GuildID: i.GuildID,
+		UserID:  InteractionUser(i).ID,
+	}, cm.Timeout)
+	defer cancel()
+
+	c = WithResponder(c, NewResponder(s, i))
+	if err := next(c, s, i); err != nil {
+		Logger(c).Error().Err(err).Msg("Unhandled error in component execution")
+	}
 }
 
 func (cm *CommandManagerImpl) RegisterStack(stack CommandStack) error {
 	data := stack.Command.Data()
+	key := NewCommandKey(data)
+
+	switch key.Type {
+	case discordgo.ChatApplicationCommand:
+		if data.Description == "" {
+			return errors.New("chat input command must have a description")
+		}
+	case discordgo.UserApplicationCommand, discordgo.MessageApplicationCommand:
+		if data.Description != "" || len(data.Options) > 0 {
+			return errors.New("context menu command cannot have a description or options")
+		}
+	default:
+		return fmt.Errorf("unsupported command type %d", key.Type)
+	}
 
-	if _, exists := cm.commands[data.Name]; exists {
+	if _, exists := cm.commands[key]; exists {
 		return errors.New("command already registered")
 	}
 
-	cm.commands[data.Name] = stack
+	cm.commands[key] = stack
 	return nil
 }
 
+func (cm *CommandManagerImpl) RegisterComponentStack(stack ComponentStack) error {
+	data := stack.Component.Data()
+
+	if data.Prefix == "" {
+		return errors.New("component prefix cannot be empty")
+	}
+
+	if _, exists := cm.components[data.Prefix]; exists {
+		return errors.New("component already registered")
+	}
+
+	cm.components[data.Prefix] = stack
+	return nil
+}
+
+// Wraps the command with its middleware into a single execute function.
+func (stack CommandStack) Compile() CommandExecuteFunc {
+	next := func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+		return stack.Command.Execute(c, s, i)
+	}
+
+	// Execute the middleware in reverse order
+	// to ensure the first middleware is executed last
+	for i := len(stack.Middleware) - 1; i >= 0; i-- {
+		mw := stack.Middleware[i]
+		next = mw.Handle(stack.Command, next)
+	}
+
+	return next
+}
+
 func CompileCommand(command Command, middleware ...CommandMiddleware) CommandStack {
 	return CommandStack{
 		Command:    command,
 		Middleware: middleware,
+		Scope:      ScopeGlobal,
 	}
 }
+
+// Returns a copy of the stack with its own execution timeout.
+func (stack CommandStack) WithTimeout(timeout time.Duration) CommandStack {
+	stack.Timeout = timeout
+	return stack
+}
+
+// Returns a copy of the stack published under the given scope.
+func (stack CommandStack) WithScope(scope CommandScope) CommandStack {
+	stack.Scope = scope
+	return stack
+}
+
+func scopeName(guildID string) string {
+	if guildID == "" {
+		return "global"
+	}
+
+	return fmt.Sprintf("guild %s", guildID)
+}
+
+func respondUnknown(s *discordgo.Session, i *discordgo.InteractionCreate, description string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "Unknown interaction",
+					Color:       ColorWarning,
+					Description: description,
+				},
+			},
+		},
+	})
+}
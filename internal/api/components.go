@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Separator used between a component prefix and its arguments in a custom ID
+const ComponentIDSeparator = ":"
+
+type ComponentExecuteFunc func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error
+type ComponentMiddlewareFunc func(component Component, next ComponentExecuteFunc) ComponentExecuteFunc
+
+type ComponentStack struct {
+	Component  Component
+	Middleware []ComponentMiddleware
+}
+
+type ComponentData struct {
+	// Prefix of the custom IDs handled by the component, a custom ID matches
+	// when it is equal to the prefix or starts with the prefix and a separator.
+	Prefix string
+}
+
+// Component handles message components (buttons, select menus) and modal
+// submissions whose custom ID starts with the component prefix.
+type Component interface {
+	Data() ComponentData
+	Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error
+}
+
+type ComponentMiddleware interface {
+	HandleComponent(component Component, next ComponentExecuteFunc) ComponentExecuteFunc
+}
+
+func CompileComponent(component Component, middleware ...ComponentMiddleware) ComponentStack {
+	return ComponentStack{
+		Component:  component,
+		Middleware: middleware,
+	}
+}
+
+// Builds a custom ID for the given prefix and arguments
+func ComponentID(prefix string, args ...string) string {
+	return strings.Join(append([]string{prefix}, args...), ComponentIDSeparator)
+}
+
+// Returns the arguments encoded in a custom ID after the component prefix
+func ComponentArgs(prefix, customID string) []string {
+	rest, ok := strings.CutPrefix(customID, prefix+ComponentIDSeparator)
+	if !ok {
+		return []string{}
+	}
+
+	return strings.Split(rest, ComponentIDSeparator)
+}
+
+// Returns the custom ID of a message component or modal submit interaction
+func InteractionCustomID(i *discordgo.InteractionCreate) (string, error) {
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		return i.MessageComponentData().CustomID, nil
+	case discordgo.InteractionModalSubmit:
+		return i.ModalSubmitData().CustomID, nil
+	}
+
+	return "", errors.New("interaction has no custom id")
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type invocationKey struct{}
+
+// Invocation describes a single execution of a command, component, event or
+// task. It is stored in the context handed to the handler.
+type Invocation struct {
+	ID      xid.ID
+	Kind    string
+	Name    string
+	GuildID string
+	UserID  string
+}
+
+// Derives the context of an invocation from the root context. The context
+// carries the invocation, a child logger (see zerolog.Ctx) and is cancelled
+// after the timeout when it is positive.
+func NewInvocationContext(root context.Context, invocation Invocation, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if invocation.ID.IsNil() {
+		invocation.ID = xid.New()
+	}
+
+	c, cancel := root, context.CancelFunc(func() {})
+	if timeout > 0 {
+		c, cancel = context.WithTimeout(root, timeout)
+	}
+
+	logger := log.Logger.With().
+		Str("invocation", invocation.ID.String()).
+		Str(invocation.Kind, invocation.Name)
+
+	if invocation.GuildID != "" {
+		logger = logger.Str("guild", invocation.GuildID)
+	}
+
+	if invocation.UserID != "" {
+		logger = logger.Str("user", invocation.UserID)
+	}
+
+	c = context.WithValue(c, invocationKey{}, invocation)
+	c = logger.Logger().WithContext(c)
+
+	return c, cancel
+}
+
+func InvocationFromContext(c context.Context) (Invocation, bool) {
+	invocation, ok := c.Value(invocationKey{}).(Invocation)
+	return invocation, ok
+}
+
+// Returns the logger of the invocation stored in the context, or the global
+// logger outside of an invocation.
+func Logger(c context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(c); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+
+	return &log.Logger
+}
+
+// Returns the guild ID of an event when it has one.
+func guildIDOf(e any) string {
+	value := reflect.ValueOf(e)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return ""
+	}
+
+	structField, ok := value.Type().FieldByName("GuildID")
+	if !ok {
+		return ""
+	}
+
+	// Embedded structs (like the message of MessageCreate) might be nil
+	field, err := value.FieldByIndexErr(structField.Index)
+	if err != nil || field.Kind() != reflect.String {
+		return ""
+	}
+
+	return field.String()
+}
+
+// Returns the user that triggered an interaction, in guilds and in DMs.
+func InteractionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+
+	if i.User != nil {
+		return i.User
+	}
+
+	return &discordgo.User{}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+)
+
+type ErrorKind int
+
+const (
+	// Unexpected failures, their message is never shown to users
+	ErrorInternal ErrorKind = iota
+	// Mistakes made by the user, like invalid input
+	ErrorUser
+	// The user is not allowed to do what was requested
+	ErrorPermission
+	// The requested resource does not exist
+	ErrorNotFound
+	// An external service failed or could not be reached
+	ErrorUnavailable
+)
+
+// Error carries a message that is safe to show to users along with the kind
+// of failure, which decides how it is presented. The cause is kept for logs.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewUserError(message string) *Error {
+	return &Error{Kind: ErrorUser, Message: message}
+}
+
+func NewPermissionError(message string) *Error {
+	return &Error{Kind: ErrorPermission, Message: message}
+}
+
+func NewNotFoundError(message string) *Error {
+	return &Error{Kind: ErrorNotFound, Message: message}
+}
+
+func NewUnavailableError(message string, err error) *Error {
+	return &Error{Kind: ErrorUnavailable, Message: message, Err: err}
+}
+
+func NewInternalError(message string, err error) *Error {
+	return &Error{Kind: ErrorInternal, Message: message, Err: err}
+}
+
+// Returns the kind of an error and the message that can be shown to users.
+// Errors without a kind are internal and have no message.
+func ClassifyError(err error) (ErrorKind, string) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind, apiErr.Message
+	}
+
+	var optionErr *OptionError
+	if errors.As(err, &optionErr) {
+		return ErrorUser, "The " + optionErr.Error() + "."
+	}
+
+	return ErrorInternal, ""
+}
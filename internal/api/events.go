@@ -3,23 +3,33 @@ package api
 import (
 	"context"
 	"errors"
-	"runtime"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/rs/zerolog/log"
 )
 
 type EventExecuteFunc[T any] func(c context.Context, s *discordgo.Session, e *T) error
 type EventMiddlewareFunc[T any] func(event Event[T], next EventExecuteFunc[T]) EventExecuteFunc[T]
 
+// Type-erased handlers, for middleware wrapping events of any type
+type AnyEventExecuteFunc func(c context.Context, s *discordgo.Session, e any) error
+
 type EventStack struct {
-	Data    EventData
-	Execute any
+	Data EventData
+
+	// Wrapped around the event and its own middleware, see ModuleManager
+	Middleware []AnyEventMiddleware
+
+	// Builds the session handler of the event bound to the root context
+	Execute func(root context.Context, timeout time.Duration, middleware []AnyEventMiddleware) any
 }
 
 type EventData struct {
 	Name string
 	Once bool
+
+	// Overrides the default execution timeout of the manager when positive
+	Timeout time.Duration
 }
 
 type Event[T any] interface {
@@ -31,17 +41,26 @@ type EventMiddleware[T any] interface {
 	Handle(event Event[T], next EventExecuteFunc[T]) EventExecuteFunc[T]
 }
 
+type AnyEventMiddleware interface {
+	HandleAny(data EventData, next AnyEventExecuteFunc) AnyEventExecuteFunc
+}
+
 type EventManager interface {
 	PublishEvents(session *discordgo.Session) error
 	RegisterStack(event EventStack) error
 }
 
 type EventManagerImpl struct {
+	// Default execution timeout of events, none when zero
+	Timeout time.Duration
+
+	root   context.Context
 	events map[string]EventStack
 }
 
-func NewEventManager() *EventManagerImpl {
+func NewEventManager(root context.Context) *EventManagerImpl {
 	return &EventManagerImpl{
+		root:   root,
 		events: make(map[string]EventStack),
 	}
 }
@@ -50,7 +69,13 @@ func (em *EventManagerImpl) PublishEvents(session *discordgo.Session) error {
 	// Wait for all events to be published
 	for _, stack := range em.events {
 		data := stack.Data
-		execute := stack.Execute
+
+		timeout := data.Timeout
+		if timeout <= 0 {
+			timeout = em.Timeout
+		}
+
+		execute := stack.Execute(em.root, timeout, stack.Middleware)
 
 		// Register the event handler with the session
 		if data.Once {
@@ -91,26 +116,41 @@ func CompileEvent[T any](event Event[T], middlewares ...EventMiddleware[T]) Even
 	}
 
 	return EventStack{
-		Data:    data,
-		Execute: WrapEvent(next),
+		Data: data,
+		Execute: func(root context.Context, timeout time.Duration, middleware []AnyEventMiddleware) any {
+			if len(middleware) == 0 {
+				return WrapEvent(root, data.Name, timeout, next)
+			}
+
+			untyped := func(c context.Context, s *discordgo.Session, e any) error {
+				return next(c, s, e.(*T))
+			}
+
+			for i := len(middleware) - 1; i >= 0; i-- {
+				untyped = middleware[i].HandleAny(data, untyped)
+			}
+
+			return WrapEvent(root, data.Name, timeout, func(c context.Context, s *discordgo.Session, e *T) error {
+				return untyped(c, s, e)
+			})
+		},
 	}
 }
 
 // Helper function that translates generic events into interfaces for discordgo
-func WrapEvent[T any](fn EventExecuteFunc[T]) interface{} {
+func WrapEvent[T any](root context.Context, name string, timeout time.Duration, fn EventExecuteFunc[T]) interface{} {
 	return func(s *discordgo.Session, e *T) {
-		defer func() {
-			if rec := recover(); rec != nil {
-				stacktrace := make([]byte, 4096)
-				count := runtime.Stack(stacktrace, false)
+		c, cancel := NewInvocationContext(root, Invocation{
+			Kind:    "event",
+			Name:    name,
+			GuildID: guildIDOf(e),
+		}, timeout)
+		defer cancel()
 
-				log.Error().Any("panic", rec).Msg("Recovered from fatal error while executing event!")
-				log.Debug().Msg("Stack trace: \n" + string(stacktrace[:count]))
-			}
-		}()
+		defer recoverInvocation(c)
 
-		if err := fn(context.Background(), s, e); err != nil {
-			log.Error().Err(err).Msg("Error executing event not handled!")
+		if err := fn(c, s, e); err != nil {
+			reportInvocationError(c, err)
 		}
 	}
 }
@@ -0,0 +1,77 @@
+package api
+
+import "time"
+
+// Outcome of an event or task execution
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeError   Outcome = "error"
+	OutcomePanic   Outcome = "panic"
+	// The run exceeded its maximum duration
+	OutcomeTimeout Outcome = "timeout"
+	// The run was skipped since the previous one was still running
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// What happens when a task is due while its previous run is still going
+type OverlapPolicy int
+
+const (
+	// The new run is skipped
+	OverlapSkip OverlapPolicy = iota
+	// The new run waits for the previous one to finish
+	OverlapQueue
+	// Both runs execute in parallel
+	OverlapAllow
+)
+
+// What happens to the runs of a task missed while the bot was down
+type CatchUpPolicy int
+
+const (
+	// Missed runs are dropped
+	CatchUpSkip CatchUpPolicy = iota
+	// A single run replaces all the missed ones
+	CatchUpOnce
+	// Every missed run is executed in order, up to MaxCatchUpRuns
+	CatchUpAll
+)
+
+// Upper bound of runs executed by CatchUpAll, for tasks with short intervals
+const MaxCatchUpRuns = 50
+
+// TaskRun is a single finished execution of a task.
+type TaskRun struct {
+	// Time the run was scheduled for, the start time for manual runs
+	Scheduled time.Time `json:"scheduled"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Outcome   Outcome   `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	ErrorID   string    `json:"error_id,omitempty"`
+}
+
+// TaskRecord keeps the latest runs of a task.
+type TaskRecord struct {
+	Name        string   `json:"name"`
+	LastRun     *TaskRun `json:"last_run,omitempty"`
+	LastSuccess *TaskRun `json:"last_success,omitempty"`
+}
+
+type TaskHistoryStore interface {
+	// Records a finished run, updating the last success when it succeeded
+	Record(name string, run TaskRun) error
+	// Returns the record of a task, empty if it never ran
+	Get(name string) (*TaskRecord, error)
+}
+
+// Returns the outcome of an execution from its error.
+func OutcomeOf(err error) Outcome {
+	if err == nil {
+		return OutcomeSuccess
+	}
+
+	return OutcomeError
+}
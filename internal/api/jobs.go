@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrJobExists          = errors.New("a task or job with this name already exists")
+	ErrNotAJob            = errors.New("task was not added as a job")
+	ErrUnknownJobHandler  = errors.New("unknown job handler")
+	ErrInvalidJobSchedule = errors.New("jobs need exactly one of cron, every or at")
+)
+
+// Runs a job with the payload it was added with.
+type JobHandler func(c context.Context, s *discordgo.Session, payload json.RawMessage) error
+
+// Job is a task added while the bot runs. Jobs run through a named handler
+// instead of a Task so persisted jobs can be restored after a restart.
+type Job struct {
+	Name    string          `json:"name"`
+	Handler string          `json:"handler"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// Exactly one of a cron spec, an interval or a single run time
+	Cron     string        `json:"cron,omitempty"`
+	Timezone string        `json:"timezone,omitempty"`
+	Every    time.Duration `json:"every,omitempty"`
+	At       time.Time     `json:"at,omitempty"`
+
+	Overlap OverlapPolicy `json:"overlap,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Persisted jobs are restored when the tasks are published again
+	Persist bool `json:"persist,omitempty"`
+}
+
+type JobStore interface {
+	SaveJob(job Job) error
+	DeleteJob(name string) error
+	LoadJobs() ([]Job, error)
+}
+
+// JobScheduler adds and removes jobs at runtime. Handlers must be registered
+// before the tasks are published for persisted jobs to be restored.
+type JobScheduler interface {
+	RegisterJobHandler(name string, handler JobHandler) error
+	// Fails when a task or job with the same name exists
+	AddJob(job Job) error
+	// Adds the job or replaces the job with the same name
+	ReplaceJob(job Job) error
+	RemoveJob(name string) error
+}
+
+// Returns the task data of the job and its schedule.
+func (job Job) compile() (TaskData, cron.Schedule, error) {
+	data := TaskData{
+		Name:     job.Name,
+		Cron:     job.Cron,
+		Timezone: job.Timezone,
+		Overlap:  job.Overlap,
+		Timeout:  job.Timeout,
+	}
+
+	kinds := 0
+	for _, set := range []bool{job.Cron != "", job.Every != 0, !job.At.IsZero()} {
+		if set {
+			kinds++
+		}
+	}
+
+	if kinds != 1 {
+		return data, nil, ErrInvalidJobSchedule
+	}
+
+	if !job.At.IsZero() {
+		return data, &onceSchedule{at: job.At}, nil
+	}
+
+	if job.Every != 0 {
+		if job.Every < time.Second {
+			return data, nil, errors.New("job interval must be at least a second")
+		}
+
+		data.Cron = fmt.Sprintf("@every %s", job.Every)
+	}
+
+	schedule, err := ParseSchedule(data.Cron, data.Timezone)
+	return data, schedule, err
+}
+
+var _ Task = (*jobTask)(nil)
+
+type jobTask struct {
+	data    TaskData
+	payload json.RawMessage
+	handler JobHandler
+}
+
+func (j *jobTask) Data() TaskData {
+	return j.data
+}
+
+func (j *jobTask) Run(c context.Context, s *discordgo.Session) error {
+	return j.handler(c, s, j.payload)
+}
+
+// Fires once at the given time, never again after it.
+type onceSchedule struct {
+	at time.Time
+}
+
+func (o *onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(o.at) {
+		return o.at
+	}
+
+	return time.Time{}
+}
+
+func (tm *TaskManagerImpl) RegisterJobHandler(name string, handler JobHandler) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	if _, exists := tm.handlers[name]; exists {
+		return errors.New("job handler already registered")
+	}
+
+	tm.handlers[name] = handler
+	return nil
+}
+
+func (tm *TaskManagerImpl) AddJob(job Job) error {
+	return tm.addJob(job, false)
+}
+
+func (tm *TaskManagerImpl) ReplaceJob(job Job) error {
+	return tm.addJob(job, true)
+}
+
+func (tm *TaskManagerImpl) RemoveJob(name string) error {
+	tm.lock.Lock()
+	task, exists := tm.tasks[name]
+	tm.lock.Unlock()
+
+	if !exists {
+		return ErrUnknownTask
+	}
+
+	if task.job == nil {
+		return ErrNotAJob
+	}
+
+	return tm.removeJob(task)
+}
+
+func (tm *TaskManagerImpl) addJob(job Job, replace bool) error {
+	data, schedule, err := job.compile()
+	if err != nil {
+		return fmt.Errorf("job %q: %w", job.Name, err)
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	handler, exists := tm.handlers[job.Handler]
+	if !exists {
+		return fmt.Errorf("job %q: %w %q", job.Name, ErrUnknownJobHandler, job.Handler)
+	}
+
+	previous, exists := tm.tasks[job.Name]
+	if exists && !replace {
+		return ErrJobExists
+	}
+
+	if exists && previous.job == nil {
+		return ErrNotAJob
+	}
+
+	if tm.Jobs != nil {
+		if job.Persist {
+			err = tm.Jobs.SaveJob(job)
+		} else if exists && previous.job.Persist {
+			err = tm.Jobs.DeleteJob(job.Name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to persist job %q: %w", job.Name, err)
+		}
+	}
+
+	if exists {
+		tm.unschedule(previous)
+	}
+
+	task := &scheduledTask{
+		stack:    CompileTasks(&jobTask{data: data, payload: job.Payload, handler: handler}, tm.JobMiddleware...),
+		data:     data,
+		schedule: schedule,
+		job:      &job,
+	}
+	tm.tasks[job.Name] = task
+
+	// Jobs added before publishing are scheduled along with the tasks
+	if tm.session != nil {
+		tm.scheduleJob(task)
+	}
+
+	return nil
+}
+
+// Schedules a job, running one-shot jobs right away when their time passed.
+// The lock must be held.
+func (tm *TaskManagerImpl) scheduleJob(task *scheduledTask) {
+	tm.scheduleTask(task)
+
+	if at := task.job.At; !at.IsZero() && !at.After(time.Now()) {
+		go tm.fire(task, at)
+	}
+}
+
+// Takes a job out of the scheduler, the lock must be held.
+func (tm *TaskManagerImpl) unschedule(task *scheduledTask) {
+	task.removed.Store(true)
+
+	// Jobs added before publishing were never scheduled
+	if task.entry != 0 {
+		tm.cron.Remove(task.entry)
+	}
+}
+
+func (tm *TaskManagerImpl) removeJob(task *scheduledTask) error {
+	name := task.data.Name
+
+	// A replacement might have taken the name (and unscheduled it) already
+	tm.lock.Lock()
+	current := tm.tasks[name] == task
+	if current {
+		delete(tm.tasks, name)
+		tm.unschedule(task)
+	}
+	tm.lock.Unlock()
+
+	if !current {
+		return nil
+	}
+
+	if task.job.Persist && tm.Jobs != nil {
+		if err := tm.Jobs.DeleteJob(name); err != nil {
+			return fmt.Errorf("failed to delete persisted job %q: %w", name, err)
+		}
+	}
+
+	log.Info().Msgf("Removed job %q", name)
+	return nil
+}
+
+// Adds back the persisted jobs, jobs added again before publishing win.
+func (tm *TaskManagerImpl) restoreJobs() {
+	if tm.Jobs == nil {
+		return
+	}
+
+	jobs, err := tm.Jobs.LoadJobs()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load persisted jobs")
+		return
+	}
+
+	for _, job := range jobs {
+		if err := tm.AddJob(job); err != nil && !errors.Is(err, ErrJobExists) {
+			log.Warn().Err(err).Msgf("Failed to restore job %q", job.Name)
+		}
+	}
+}
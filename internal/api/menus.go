@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrTargetNotResolved = errors.New("context menu target could not be resolved")
+
+// UserCommand is a context menu command shown when right-clicking a user. The
+// command type is set by the framework.
+type UserCommand interface {
+	Data() discordgo.ApplicationCommand
+	ExecuteUser(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.User, member *discordgo.Member) error
+}
+
+// MessageCommand is a context menu command shown when right-clicking a
+// message. The command type is set by the framework.
+type MessageCommand interface {
+	Data() discordgo.ApplicationCommand
+	ExecuteMessage(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error
+}
+
+type userCommandAdapter struct {
+	command UserCommand
+}
+
+func (a *userCommandAdapter) Data() discordgo.ApplicationCommand {
+	data := a.command.Data()
+	data.Type = discordgo.UserApplicationCommand
+	return data
+}
+
+func (a *userCommandAdapter) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	target, member, err := TargetUser(i)
+	if err != nil {
+		return err
+	}
+
+	return a.command.ExecuteUser(c, s, i, target, member)
+}
+
+type messageCommandAdapter struct {
+	command MessageCommand
+}
+
+func (a *messageCommandAdapter) Data() discordgo.ApplicationCommand {
+	data := a.command.Data()
+	data.Type = discordgo.MessageApplicationCommand
+	return data
+}
+
+func (a *messageCommandAdapter) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	target, err := TargetMessage(i)
+	if err != nil {
+		return err
+	}
+
+	return a.command.ExecuteMessage(c, s, i, target)
+}
+
+func CompileUserCommand(command UserCommand, middleware ...CommandMiddleware) CommandStack {
+	return CompileCommand(&userCommandAdapter{command: command}, middleware...)
+}
+
+func CompileMessageCommand(command MessageCommand, middleware ...CommandMiddleware) CommandStack {
+	return CompileCommand(&messageCommandAdapter{command: command}, middleware...)
+}
+
+// Returns the user a user command was invoked on, the member is only
+// available when the command was used in a guild.
+func TargetUser(i *discordgo.InteractionCreate) (*discordgo.User, *discordgo.Member, error) {
+	data := i.ApplicationCommandData()
+	if data.CommandType != discordgo.UserApplicationCommand || data.Resolved == nil {
+		return nil, nil, ErrTargetNotResolved
+	}
+
+	user, exists := data.Resolved.Users[data.TargetID]
+	if !exists {
+		return nil, nil, ErrTargetNotResolved
+	}
+
+	member := data.Resolved.Members[data.TargetID]
+	if member != nil {
+		member.User = user
+	}
+
+	return user, member, nil
+}
+
+// Returns the message a message command was invoked on.
+func TargetMessage(i *discordgo.InteractionCreate) (*discordgo.Message, error) {
+	data := i.ApplicationCommandData()
+	if data.CommandType != discordgo.MessageApplicationCommand || data.Resolved == nil {
+		return nil, ErrTargetNotResolved
+	}
+
+	message, exists := data.Resolved.Messages[data.TargetID]
+	if !exists {
+		return nil, ErrTargetNotResolved
+	}
+
+	return message, nil
+}
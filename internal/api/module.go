@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -10,10 +11,20 @@ type Module interface {
 	Events() ([]EventStack, error)
 	Tasks() ([]TaskStack, error)
 	Commands() ([]CommandStack, error)
+	Components() ([]ComponentStack, error)
+	// Handlers of the jobs the module adds at runtime, keyed by handler name
+	JobHandlers() (map[string]JobHandler, error)
 }
 
 type ModuleManager struct {
 	Modules []Module
+
+	// Middleware applied to the stacks of every module. It runs before (wraps)
+	// the middleware declared by the module, in the given order.
+	CommandMiddleware   []CommandMiddleware
+	ComponentMiddleware []ComponentMiddleware
+	EventMiddleware     []AnyEventMiddleware
+	TaskMiddleware      []TaskMiddleware
 }
 
 func NewModuleManager() *ModuleManager {
@@ -36,6 +47,7 @@ func (m *ModuleManager) OnEvents(client *discordgo.Session, manager EventManager
 		}
 
 		for _, stack := range events {
+			stack.Middleware = append(slices.Clone(m.EventMiddleware), stack.Middleware...)
 			if err := manager.RegisterStack(stack); err != nil {
 				return fmt.Errorf("failed to register event for module %T: %w", module, err)
 			}
@@ -59,10 +71,23 @@ func (m *ModuleManager) OnCommands(client *discordgo.Session, manager CommandMan
 		}
 
 		for _, stack := range commands {
+			stack.Middleware = append(slices.Clone(m.CommandMiddleware), stack.Middleware...)
 			if err := manager.RegisterStack(stack); err != nil {
 				return fmt.Errorf("failed to register command for module %T: %w", module, err)
 			}
 		}
+
+		components, err := module.Components()
+		if err != nil {
+			return fmt.Errorf("failed to factory components for module %T: %w", module, err)
+		}
+
+		for _, stack := range components {
+			stack.Middleware = append(slices.Clone(m.ComponentMiddleware), stack.Middleware...)
+			if err := manager.RegisterComponentStack(stack); err != nil {
+				return fmt.Errorf("failed to register component for module %T: %w", module, err)
+			}
+		}
 	}
 
 	// Publish commands
@@ -76,12 +101,25 @@ func (m *ModuleManager) OnCommands(client *discordgo.Session, manager CommandMan
 func (m *ModuleManager) OnTasks(client *discordgo.Session, manager TaskManager) error {
 	// Register tasks
 	for _, module := range m.Modules {
+		// Handlers go first, persisted jobs are restored once tasks are published
+		handlers, err := module.JobHandlers()
+		if err != nil {
+			return fmt.Errorf("failed to factory job handlers for module %T: %w", module, err)
+		}
+
+		for name, handler := range handlers {
+			if err := manager.RegisterJobHandler(name, handler); err != nil {
+				return fmt.Errorf("failed to register job handler %q for module %T: %w", name, module, err)
+			}
+		}
+
 		tasks, err := module.Tasks()
 		if err != nil {
 			return fmt.Errorf("failed to factory tasks for module %T: %w", module, err)
 		}
 
 		for _, stack := range tasks {
+			stack.Middleware = append(slices.Clone(m.TaskMiddleware), stack.Middleware...)
 			if err := manager.RegisterStack(stack); err != nil {
 				return fmt.Errorf("failed to register task for module %T: %w", module, err)
 			}
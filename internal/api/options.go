@@ -0,0 +1,415 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrInvalidOptionsTarget = errors.New("options target must be a pointer to a struct")
+
+// OptionError is returned when an option sent by the user is missing or fails
+// validation, its message is meant to be shown to the user.
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option `%s` %s", e.Option, e.Reason)
+}
+
+// Mentionable holds the resolved target of a mentionable option, either the
+// user (and member when in a guild) or the role is set.
+type Mentionable struct {
+	User   *discordgo.User
+	Member *discordgo.Member
+	Role   *discordgo.Role
+}
+
+var (
+	userType        = reflect.TypeOf(&discordgo.User{})
+	memberType      = reflect.TypeOf(&discordgo.Member{})
+	roleType        = reflect.TypeOf(&discordgo.Role{})
+	channelType     = reflect.TypeOf(&discordgo.Channel{})
+	attachmentType  = reflect.TypeOf(&discordgo.MessageAttachment{})
+	mentionableType = reflect.TypeOf(Mentionable{})
+)
+
+// Decodes the options of the invoked command (or subcommand) into the struct
+// pointed by target. Fields are bound with the following tags:
+//
+//	option:"name"       name of the option, fields without it are ignored
+//	required:"true"     fails when the option is missing
+//	default:"value"     value used when the option is missing
+//	min:"1" max:"10"    bounds of numbers, or length of strings
+//	choices:"a,b"       allowed values, in "Name=value" or "value" form
+func BindOptions(i *discordgo.InteractionCreate, target any) error {
+	data := i.ApplicationCommandData()
+	return BindOptionList(SubcommandOptions(data.Options), data.Resolved, target)
+}
+
+func BindOptionList(options []*discordgo.ApplicationCommandInteractionDataOption, resolved *discordgo.ApplicationCommandInteractionDataResolved, target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrInvalidOptionsTarget
+	}
+
+	if resolved == nil {
+		resolved = &discordgo.ApplicationCommandInteractionDataResolved{}
+	}
+
+	provided := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, option := range options {
+		provided[option.Name] = option
+	}
+
+	value = value.Elem()
+	fields, err := ParseOptionFields(value.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		dst := value.Field(field.Index)
+
+		option, exists := provided[field.Name]
+		if !exists {
+			if field.Required {
+				return &OptionError{Option: field.Name, Reason: "is required"}
+			}
+
+			if field.HasDefault {
+				if err := setDefault(dst, field.Default); err != nil {
+					return fmt.Errorf("invalid default for option %q: %w", field.Name, err)
+				}
+			}
+
+			continue
+		}
+
+		if option.Type != field.Type {
+			return &OptionError{Option: field.Name, Reason: "has an unexpected type"}
+		}
+
+		if err := bindOption(dst, option, resolved); err != nil {
+			return &OptionError{Option: field.Name, Reason: err.Error()}
+		}
+
+		if err := field.Validate(option); err != nil {
+			return &OptionError{Option: field.Name, Reason: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// OptionField describes a struct field bound to a command option.
+type OptionField struct {
+	Index      int
+	Name       string
+	Type       discordgo.ApplicationCommandOptionType
+	Required   bool
+	HasDefault bool
+	Default    string
+	Min        *float64
+	Max        *float64
+	Choices    []*discordgo.ApplicationCommandOptionChoice
+	Tag        reflect.StructTag
+}
+
+// Parses the option fields of a struct type.
+func ParseOptionFields(t reflect.Type) ([]OptionField, error) {
+	fields := make([]OptionField, 0, t.NumField())
+
+	for i := range t.NumField() {
+		structField := t.Field(i)
+
+		name, ok := structField.Tag.Lookup("option")
+		if !ok || name == "-" || !structField.IsExported() {
+			continue
+		}
+
+		optionType, err := OptionTypeOf(structField.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+		}
+
+		field := OptionField{
+			Index:    i,
+			Name:     name,
+			Type:     optionType,
+			Required: structField.Tag.Get("required") == "true",
+			Tag:      structField.Tag,
+		}
+
+		field.Default, field.HasDefault = structField.Tag.Lookup("default")
+
+		if field.Min, err = parseBound(structField.Tag, "min"); err != nil {
+			return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+		}
+
+		if field.Max, err = parseBound(structField.Tag, "max"); err != nil {
+			return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+		}
+
+		if choices, ok := structField.Tag.Lookup("choices"); ok {
+			if field.Choices, err = parseChoices(choices, optionType); err != nil {
+				return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// Returns the option type matching a Go type.
+func OptionTypeOf(t reflect.Type) (discordgo.ApplicationCommandOptionType, error) {
+	switch t {
+	case userType, memberType:
+		return discordgo.ApplicationCommandOptionUser, nil
+	case roleType:
+		return discordgo.ApplicationCommandOptionRole, nil
+	case channelType:
+		return discordgo.ApplicationCommandOptionChannel, nil
+	case attachmentType:
+		return discordgo.ApplicationCommandOptionAttachment, nil
+	case mentionableType:
+		return discordgo.ApplicationCommandOptionMentionable, nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return discordgo.ApplicationCommandOptionString, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return discordgo.ApplicationCommandOptionInteger, nil
+	case reflect.Float32, reflect.Float64:
+		return discordgo.ApplicationCommandOptionNumber, nil
+	case reflect.Bool:
+		return discordgo.ApplicationCommandOptionBoolean, nil
+	}
+
+	return 0, fmt.Errorf("unsupported option type %s", t)
+}
+
+// Checks the bounds and choices of an option value.
+func (f OptionField) Validate(option *discordgo.ApplicationCommandInteractionDataOption) error {
+	var value float64
+	var unit string
+
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionString:
+		value, unit = float64(utf8.RuneCountInString(option.StringValue())), " characters"
+	case discordgo.ApplicationCommandOptionInteger:
+		value = float64(option.IntValue())
+	case discordgo.ApplicationCommandOptionNumber:
+		value = option.FloatValue()
+	default:
+		return nil
+	}
+
+	if f.Min != nil && value < *f.Min {
+		return fmt.Errorf("must be at least %s%s", strconv.FormatFloat(*f.Min, 'f', -1, 64), unit)
+	}
+
+	if f.Max != nil && value > *f.Max {
+		return fmt.Errorf("must be at most %s%s", strconv.FormatFloat(*f.Max, 'f', -1, 64), unit)
+	}
+
+	if len(f.Choices) > 0 {
+		allowed := slices.ContainsFunc(f.Choices, func(choice *discordgo.ApplicationCommandOptionChoice) bool {
+			return fmt.Sprint(choice.Value) == fmt.Sprint(option.Value)
+		})
+
+		if !allowed {
+			return errors.New("is not one of the allowed choices")
+		}
+	}
+
+	return nil
+}
+
+func bindOption(dst reflect.Value, option *discordgo.ApplicationCommandInteractionDataOption, resolved *discordgo.ApplicationCommandInteractionDataResolved) error {
+	id := fmt.Sprint(option.Value)
+
+	switch dst.Type() {
+	case userType:
+		user, exists := resolved.Users[id]
+		if !exists {
+			return errors.New("user could not be resolved")
+		}
+
+		dst.Set(reflect.ValueOf(user))
+		return nil
+	case memberType:
+		member, exists := resolved.Members[id]
+		if !exists {
+			return errors.New("must be a member of this server")
+		}
+
+		member.User = resolved.Users[id]
+		dst.Set(reflect.ValueOf(member))
+		return nil
+	case roleType:
+		role, exists := resolved.Roles[id]
+		if !exists {
+			return errors.New("role could not be resolved")
+		}
+
+		dst.Set(reflect.ValueOf(role))
+		return nil
+	case channelType:
+		channel, exists := resolved.Channels[id]
+		if !exists {
+			return errors.New("channel could not be resolved")
+		}
+
+		dst.Set(reflect.ValueOf(channel))
+		return nil
+	case attachmentType:
+		attachment, exists := resolved.Attachments[id]
+		if !exists {
+			return errors.New("attachment could not be resolved")
+		}
+
+		dst.Set(reflect.ValueOf(attachment))
+		return nil
+	case mentionableType:
+		mentionable := Mentionable{
+			User:   resolved.Users[id],
+			Member: resolved.Members[id],
+			Role:   resolved.Roles[id],
+		}
+
+		if mentionable.User == nil && mentionable.Role == nil {
+			return errors.New("mention could not be resolved")
+		}
+
+		if mentionable.Member != nil {
+			mentionable.Member.User = mentionable.User
+		}
+
+		dst.Set(reflect.ValueOf(mentionable))
+		return nil
+	}
+
+	// Allocate optional scalar values
+	if dst.Kind() == reflect.Pointer {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		dst = dst.Elem()
+	}
+
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionString:
+		dst.SetString(option.StringValue())
+	case discordgo.ApplicationCommandOptionInteger:
+		dst.SetInt(option.IntValue())
+	case discordgo.ApplicationCommandOptionNumber:
+		dst.SetFloat(option.FloatValue())
+	case discordgo.ApplicationCommandOptionBoolean:
+		dst.SetBool(option.BoolValue())
+	}
+
+	return nil
+}
+
+func setDefault(dst reflect.Value, value string) error {
+	if dst.Kind() == reflect.Pointer {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		dst = dst.Elem()
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		dst.SetInt(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		dst.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		dst.SetBool(parsed)
+	default:
+		return fmt.Errorf("defaults are not supported for %s", dst.Type())
+	}
+
+	return nil
+}
+
+func parseBound(tag reflect.StructTag, key string) (*float64, error) {
+	value, ok := tag.Lookup(key)
+	if !ok {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+
+	return &parsed, nil
+}
+
+func parseChoices(value string, optionType discordgo.ApplicationCommandOptionType) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	for _, entry := range strings.Split(value, ",") {
+		name, raw, found := strings.Cut(entry, "=")
+		if !found {
+			raw = name
+		}
+
+		choice := &discordgo.ApplicationCommandOptionChoice{Name: name}
+
+		switch optionType {
+		case discordgo.ApplicationCommandOptionString:
+			choice.Value = raw
+		case discordgo.ApplicationCommandOptionInteger:
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid choice %q: %w", raw, err)
+			}
+
+			choice.Value = parsed
+		case discordgo.ApplicationCommandOptionNumber:
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid choice %q: %w", raw, err)
+			}
+
+			choice.Value = parsed
+		default:
+			return nil, errors.New("choices are only supported for strings and numbers")
+		}
+
+		choices = append(choices, choice)
+	}
+
+	return choices, nil
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type testOptions struct {
+	Query  string            `option:"query" required:"true" max:"5"`
+	Page   int               `option:"page" default:"1" min:"1"`
+	Scale  *float64          `option:"scale"`
+	Sort   string            `option:"sort" choices:"New=new,Top=top" default:"new"`
+	Public bool              `option:"public"`
+	Member *discordgo.Member `option:"member"`
+	Role   *discordgo.Role   `option:"role"`
+	Ignore string
+}
+
+func option(name string, optionType discordgo.ApplicationCommandOptionType, value any) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: optionType, Value: value}
+}
+
+func TestBindOptionList(t *testing.T) {
+	user := &discordgo.User{ID: "10", Username: "fox"}
+	member := &discordgo.Member{Nick: "foxy"}
+	role := &discordgo.Role{ID: "20", Name: "admin"}
+
+	resolved := &discordgo.ApplicationCommandInteractionDataResolved{
+		Users:   map[string]*discordgo.User{"10": user},
+		Members: map[string]*discordgo.Member{"10": member},
+		Roles:   map[string]*discordgo.Role{"20": role},
+	}
+
+	scale := 1.5
+
+	tests := []struct {
+		name    string
+		options []*discordgo.ApplicationCommandInteractionDataOption
+		want    testOptions
+		// Option named in the expected OptionError, none when empty
+		invalid string
+	}{
+		{
+			name:    "defaults",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{option("query", discordgo.ApplicationCommandOptionString, "fox")},
+			want:    testOptions{Query: "fox", Page: 1, Sort: "new"},
+		},
+		{
+			name: "every type",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("query", discordgo.ApplicationCommandOptionString, "fox"),
+				// Discord sends integers as JSON numbers
+				option("page", discordgo.ApplicationCommandOptionInteger, float64(3)),
+				option("scale", discordgo.ApplicationCommandOptionNumber, 1.5),
+				option("sort", discordgo.ApplicationCommandOptionString, "top"),
+				option("public", discordgo.ApplicationCommandOptionBoolean, true),
+				option("member", discordgo.ApplicationCommandOptionUser, "10"),
+				option("role", discordgo.ApplicationCommandOptionRole, "20"),
+			},
+			want: testOptions{Query: "fox", Page: 3, Scale: &scale, Sort: "top", Public: true, Member: &discordgo.Member{Nick: "foxy", User: user}, Role: role},
+		},
+		{
+			name:    "missing required option",
+			options: nil,
+			invalid: "query",
+		},
+		{
+			name:    "string too long",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{option("query", discordgo.ApplicationCommandOptionString, "foxes!")},
+			invalid: "query",
+		},
+		{
+			name:    "length counts runes",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{option("query", discordgo.ApplicationCommandOptionString, "ñññññ")},
+			want:    testOptions{Query: "ñññññ", Page: 1, Sort: "new"},
+		},
+		{
+			name: "number below the minimum",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("query", discordgo.ApplicationCommandOptionString, "fox"),
+				option("page", discordgo.ApplicationCommandOptionInteger, float64(0)),
+			},
+			invalid: "page",
+		},
+		{
+			name: "unknown choice",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("query", discordgo.ApplicationCommandOptionString, "fox"),
+				option("sort", discordgo.ApplicationCommandOptionString, "old"),
+			},
+			invalid: "sort",
+		},
+		{
+			name: "unexpected type",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("query", discordgo.ApplicationCommandOptionInteger, float64(1)),
+			},
+			invalid: "query",
+		},
+		{
+			name: "unresolved member",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("query", discordgo.ApplicationCommandOptionString, "fox"),
+				option("member", discordgo.ApplicationCommandOptionUser, "11"),
+			},
+			invalid: "member",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got testOptions
+			err := BindOptionList(test.options, resolved, &got)
+
+			if test.invalid != "" {
+				var optionErr *OptionError
+				if !errors.As(err, &optionErr) || optionErr.Option != test.invalid {
+					t.Fatalf("BindOptionList() = %v, want an error for option %q", err, test.invalid)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBindOptionListTarget(t *testing.T) {
+	targets := []any{nil, testOptions{}, new(string), (*testOptions)(nil)}
+
+	for _, target := range targets {
+		if err := BindOptionList(nil, nil, target); !errors.Is(err, ErrInvalidOptionsTarget) {
+			t.Errorf("BindOptionList(%T) = %v, want ErrInvalidOptionsTarget", target, err)
+		}
+	}
+}
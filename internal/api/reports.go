@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/xid"
+)
+
+var ErrReportNotFound = errors.New("error report not found")
+
+// ErrorReport is the persisted record of an error or panic caught while
+// handling an invocation.
+type ErrorReport struct {
+	ID      xid.ID    `json:"id"`
+	Panic   bool      `json:"panic"`
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`
+	UserID  string    `json:"user_id,omitempty"`
+	GuildID string    `json:"guild_id,omitempty"`
+	Options string    `json:"options,omitempty"`
+	Message string    `json:"message"`
+	Stack   string    `json:"stack,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+type ErrorReportStore interface {
+	Save(report *ErrorReport) error
+	Get(id xid.ID) (*ErrorReport, error)
+	// Returns the latest reports, newest first
+	Recent(limit int) ([]*ErrorReport, error)
+}
+
+// Builds a report from the invocation stored in the context.
+func NewReport(c context.Context, id xid.ID, message string) *ErrorReport {
+	report := &ErrorReport{
+		ID:      id,
+		Message: message,
+		Time:    time.Now(),
+	}
+
+	if invocation, ok := InvocationFromContext(c); ok {
+		report.Kind = invocation.Kind
+		report.Name = invocation.Name
+		report.GuildID = invocation.GuildID
+		report.UserID = invocation.UserID
+	}
+
+	return report
+}
+
+// Builds the report of an interaction, the options are kept as JSON so they
+// can be inspected later.
+func NewInteractionReport(c context.Context, i *discordgo.InteractionCreate, id xid.ID, message string) *ErrorReport {
+	report := NewReport(c, id, message)
+	report.UserID = InteractionUser(i).ID
+	report.GuildID = i.GuildID
+
+	var options any
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		options = i.ApplicationCommandData().Options
+	case discordgo.InteractionMessageComponent:
+		data := i.MessageComponentData()
+		options = map[string]any{"custom_id": data.CustomID, "values": data.Values}
+	}
+
+	if data, err := json.Marshal(options); err == nil && options != nil {
+		report.Options = string(data)
+	}
+
+	return report
+}
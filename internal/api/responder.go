@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrNotResponded = errors.New("interaction has not been responded to yet")
+
+type ResponseState int
+
+const (
+	ResponseNone ResponseState = iota
+	ResponseDeferred
+	ResponseReplied
+	ResponseFollowedUp
+)
+
+type responderKey struct{}
+
+// Responder answers an interaction while keeping track of what was already
+// sent, so replies can be turned into edits or follow-ups as needed.
+type Responder struct {
+	session     *discordgo.Session
+	interaction *discordgo.Interaction
+
+	// Serializes the requests and is held across them, the state only
+	// changes while it is held
+	send sync.Mutex
+
+	// Guards the state for readers, never held across requests
+	lock      sync.Mutex
+	state     ResponseState
+	ephemeral bool
+	// Deferred as an update of the message a component is attached to
+	update bool
+}
+
+func NewResponder(s *discordgo.Session, i *discordgo.InteractionCreate) *Responder {
+	return &Responder{
+		session:     s,
+		interaction: i.Interaction,
+	}
+}
+
+func WithResponder(c context.Context, responder *Responder) context.Context {
+	return context.WithValue(c, responderKey{}, responder)
+}
+
+func ResponderFromContext(c context.Context) (*Responder, bool) {
+	responder, ok := c.Value(responderKey{}).(*Responder)
+	return responder, ok
+}
+
+// Returns the responder of the interaction stored in the context, or a new
+// one when the handler was invoked outside of the command manager.
+func GetResponder(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) *Responder {
+	if responder, ok := ResponderFromContext(c); ok {
+		return responder
+	}
+
+	return NewResponder(s, i)
+}
+
+func (r *Responder) State() ResponseState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.state
+}
+
+// Reports whether the first response (reply or defer) was ephemeral.
+func (r *Responder) Ephemeral() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.ephemeral
+}
+
+// Sends a raw interaction response and records the resulting state. When the
+// interaction was already answered (for example by an automatic deferral)
+// messages are sent as a reply and deferrals are skipped.
+func (r *Responder) Respond(response *discordgo.InteractionResponse) error {
+	r.send.Lock()
+	defer r.send.Unlock()
+
+	if r.state != ResponseNone {
+		switch response.Type {
+		case discordgo.InteractionResponseChannelMessageWithSource:
+			return r.reply(response.Data)
+		case discordgo.InteractionResponseDeferredChannelMessageWithSource:
+			return nil
+		}
+	}
+
+	return r.respond(response)
+}
+
+// Replies with a message. Deferred interactions get their response edited,
+// already answered interactions get a follow-up instead.
+func (r *Responder) Reply(data *discordgo.InteractionResponseData) error {
+	r.send.Lock()
+	defer r.send.Unlock()
+
+	return r.reply(data)
+}
+
+func (r *Responder) reply(data *discordgo.InteractionResponseData) error {
+	if data == nil {
+		data = &discordgo.InteractionResponseData{}
+	}
+
+	switch r.state {
+	case ResponseNone:
+		return r.respond(&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: data,
+		})
+	case ResponseDeferred:
+		// The loading message keeps the visibility it was deferred with, so
+		// a reply that doesn't match it replaces it with a follow-up instead.
+		if ephemeral := data.Flags&discordgo.MessageFlagsEphemeral > 0; ephemeral != r.ephemeral {
+			// The loading message is deleted first as the first follow-up
+			// would otherwise edit it. Deferred updates point at the message
+			// of the component instead, which must be kept.
+			if !r.update {
+				if err := r.session.InteractionResponseDelete(r.interaction); err != nil {
+					return err
+				}
+			}
+
+			return r.followupData(data)
+		}
+
+		_, err := r.edit(&discordgo.WebhookEdit{
+			Content:         &data.Content,
+			Embeds:          &data.Embeds,
+			Components:      &data.Components,
+			Files:           data.Files,
+			AllowedMentions: data.AllowedMentions,
+		})
+		return err
+	default:
+		return r.followupData(data)
+	}
+}
+
+func (r *Responder) followupData(data *discordgo.InteractionResponseData) error {
+	_, err := r.followup(&discordgo.WebhookParams{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Components:      data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+		Flags:           data.Flags,
+	})
+	return err
+}
+
+// Acknowledges the interaction showing a loading state, does nothing when
+// the interaction was already answered.
+func (r *Responder) Defer(ephemeral bool) error {
+	r.send.Lock()
+	defer r.send.Unlock()
+
+	if r.state != ResponseNone {
+		return nil
+	}
+
+	var flags discordgo.MessageFlags
+	if ephemeral {
+		flags |= discordgo.MessageFlagsEphemeral
+	}
+
+	return r.respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: flags,
+		},
+	})
+}
+
+// Edits the original response.
+func (r *Responder) Edit(edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	r.send.Lock()
+	defer r.send.Unlock()
+
+	return r.edit(edit)
+}
+
+// Sends a follow-up message, the interaction must have been answered first.
+func (r *Responder) Followup(params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	r.send.Lock()
+	defer r.send.Unlock()
+
+	return r.followup(params)
+}
+
+func (r *Responder) respond(response *discordgo.InteractionResponse) error {
+	if err := r.session.InteractionRespond(r.interaction, response); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch response.Type {
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource, discordgo.InteractionResponseDeferredMessageUpdate:
+		r.state = ResponseDeferred
+	default:
+		r.state = ResponseReplied
+	}
+
+	r.update = response.Type == discordgo.InteractionResponseDeferredMessageUpdate
+	if response.Data != nil {
+		r.ephemeral = response.Data.Flags&discordgo.MessageFlagsEphemeral > 0
+	}
+
+	return nil
+}
+
+func (r *Responder) edit(edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	if r.state == ResponseNone {
+		return nil, ErrNotResponded
+	}
+
+	message, err := r.session.InteractionResponseEdit(r.interaction, edit)
+	if err != nil {
+		return nil, err
+	}
+
+	// The loading state is gone once the response is edited
+	if r.state == ResponseDeferred {
+		r.setState(ResponseReplied)
+	}
+
+	return message, nil
+}
+
+func (r *Responder) followup(params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	if r.state == ResponseNone {
+		return nil, ErrNotResponded
+	}
+
+	message, err := r.session.FollowupMessageCreate(r.interaction, true, params)
+	if err != nil {
+		return nil, err
+	}
+
+	r.setState(ResponseFollowedUp)
+	return message, nil
+}
+
+func (r *Responder) setState(state ResponseState) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.state = state
+}
+
+// EphemeralCommand is implemented by commands whose responses are ephemeral,
+// so responses sent on their behalf (like automatic deferrals) match them.
+type EphemeralCommand interface {
+	Ephemeral() bool
+}
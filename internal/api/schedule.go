@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	standardParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	secondsParser  = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+// The scheduler only fires the schedules from ParseSchedule, so its own parser
+// never runs. Unlike v1, cron v3 doesn't recover panicking jobs by default,
+// the runs recover their own panics but anything firing them must not take the
+// bot down either.
+func newCron() *cron.Cron {
+	return cron.New(
+		cron.WithLocation(time.Local),
+		cron.WithChain(cron.Recover(cronLogger{})),
+	)
+}
+
+// Parses a cron spec in the given IANA timezone (local time when empty). The
+// spec is either the standard 5 fields, 6 fields with leading seconds or a
+// descriptor like "@daily" or "@every 1h".
+func ParseSchedule(spec, timezone string) (cron.Schedule, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	// cron v3 reads timezone prefixes from the spec itself, the timezone has a
+	// field of its own so both can't disagree
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("invalid cron spec %q: use the timezone instead of a TZ prefix", spec)
+	}
+
+	parser := standardParser
+	if len(strings.Fields(spec)) == 6 {
+		parser = secondsParser
+	}
+
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron spec %q: %w", spec, err)
+	}
+
+	return &locationSchedule{schedule: schedule, location: location}, nil
+}
+
+// The scheduler runs in a single location, each schedule computes its next
+// run in its own instead.
+type locationSchedule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+func (l *locationSchedule) Next(t time.Time) time.Time {
+	return l.schedule.Next(t.In(l.location))
+}
+
+// Sends the scheduler's own logs to zerolog, only errors are worth keeping.
+type cronLogger struct{}
+
+func (cronLogger) Info(msg string, keysAndValues ...any) {}
+
+func (cronLogger) Error(err error, msg string, keysAndValues ...any) {
+	log.Error().Err(err).Fields(keysAndValues).Msgf("Cron scheduler: %s", msg)
+}
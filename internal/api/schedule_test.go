@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	from := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		next     time.Time
+		invalid  bool
+	}{
+		{name: "standard fields", spec: "30 23 * * *", timezone: "UTC", next: time.Date(2026, time.January, 10, 23, 30, 0, 0, time.UTC)},
+		{name: "leading seconds", spec: "15 30 23 * * *", timezone: "UTC", next: time.Date(2026, time.January, 10, 23, 30, 15, 0, time.UTC)},
+		{name: "descriptor", spec: "@daily", timezone: "UTC", next: time.Date(2026, time.January, 11, 0, 0, 0, 0, time.UTC)},
+		{name: "interval", spec: "@every 1h", timezone: "UTC", next: from.Add(time.Hour)},
+		{name: "timezone", spec: "0 12 * * *", timezone: "Europe/Madrid", next: time.Date(2026, time.January, 11, 12, 0, 0, 0, madrid)},
+		{name: "unknown timezone", spec: "0 12 * * *", timezone: "Mars/Olympus", invalid: true},
+		{name: "timezone prefix", spec: "CRON_TZ=UTC 0 12 * * *", invalid: true},
+		{name: "too many fields", spec: "0 0 12 * * * *", invalid: true},
+		{name: "out of range", spec: "0 25 * * *", invalid: true},
+		{name: "empty", spec: "", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.spec, test.timezone)
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("ParseSchedule(%q, %q) = nil error, want one", test.spec, test.timezone)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if next := schedule.Next(from); !next.Equal(test.next) {
+				t.Errorf("Next() = %s, want %s", next, test.next)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var channelTypes = map[string]discordgo.ChannelType{
+	"text":           discordgo.ChannelTypeGuildText,
+	"voice":          discordgo.ChannelTypeGuildVoice,
+	"category":       discordgo.ChannelTypeGuildCategory,
+	"news":           discordgo.ChannelTypeGuildNews,
+	"news-thread":    discordgo.ChannelTypeGuildNewsThread,
+	"public-thread":  discordgo.ChannelTypeGuildPublicThread,
+	"private-thread": discordgo.ChannelTypeGuildPrivateThread,
+	"stage":          discordgo.ChannelTypeGuildStageVoice,
+	"forum":          discordgo.ChannelTypeGuildForum,
+	"media":          discordgo.ChannelTypeGuildMedia,
+}
+
+// Builds the command options out of a struct bound with BindOptions, so the
+// command data and its handler share the same definition. On top of the
+// binding tags the following ones are used:
+//
+//	description:"text"                     description of the option
+//	autocomplete:"true"                    enables autocomplete for the option
+//	channels:"text,news"                   allowed channel types of channel options
+//	name_localizations:"es-ES=a;fr=b"      localized names, separated by ";"
+//	description_localizations:"es-ES=a"    localized descriptions, separated by ";"
+//
+// Required options are placed before optional ones as Discord expects.
+func GenerateOptions(target any) ([]*discordgo.ApplicationCommandOption, error) {
+	t := reflect.TypeOf(target)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, ErrInvalidOptionsTarget
+	}
+
+	fields, err := ParseOptionFields(t)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(fields))
+	for _, field := range fields {
+		option, err := field.Option()
+		if err != nil {
+			return nil, fmt.Errorf("option %q: %w", field.Name, err)
+		}
+
+		options = append(options, option)
+	}
+
+	slices.SortStableFunc(options, func(a, b *discordgo.ApplicationCommandOption) int {
+		switch {
+		case a.Required == b.Required:
+			return 0
+		case a.Required:
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	return options, nil
+}
+
+// Same as GenerateOptions but panics on invalid definitions, meant to be used
+// in Data() where the struct is known at compile time.
+func MustGenerateOptions(target any) []*discordgo.ApplicationCommandOption {
+	options, err := GenerateOptions(target)
+	if err != nil {
+		panic(err)
+	}
+
+	return options
+}
+
+// Returns the published definition of the option field.
+func (f OptionField) Option() (*discordgo.ApplicationCommandOption, error) {
+	option := &discordgo.ApplicationCommandOption{
+		Type:         f.Type,
+		Name:         f.Name,
+		Description:  f.Tag.Get("description"),
+		Required:     f.Required,
+		Autocomplete: f.Tag.Get("autocomplete") == "true",
+		Choices:      f.Choices,
+	}
+
+	if option.Description == "" {
+		return nil, fmt.Errorf("missing description")
+	}
+
+	if option.Autocomplete && len(option.Choices) > 0 {
+		return nil, fmt.Errorf("autocomplete cannot be used with choices")
+	}
+
+	switch f.Type {
+	case discordgo.ApplicationCommandOptionString:
+		if f.Min != nil {
+			length := int(*f.Min)
+			option.MinLength = &length
+		}
+
+		if f.Max != nil {
+			option.MaxLength = int(*f.Max)
+		}
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		option.MinValue = f.Min
+		if f.Max != nil {
+			option.MaxValue = *f.Max
+		}
+	}
+
+	if channels, ok := f.Tag.Lookup("channels"); ok {
+		if f.Type != discordgo.ApplicationCommandOptionChannel {
+			return nil, fmt.Errorf("channel types are only supported for channel options")
+		}
+
+		for _, name := range strings.Split(channels, ",") {
+			channelType, exists := channelTypes[strings.TrimSpace(name)]
+			if !exists {
+				return nil, fmt.Errorf("unknown channel type %q", name)
+			}
+
+			option.ChannelTypes = append(option.ChannelTypes, channelType)
+		}
+	}
+
+	var err error
+	if option.NameLocalizations, err = parseLocalizations(f.Tag.Get("name_localizations")); err != nil {
+		return nil, err
+	}
+
+	if option.DescriptionLocalizations, err = parseLocalizations(f.Tag.Get("description_localizations")); err != nil {
+		return nil, err
+	}
+
+	return option, nil
+}
+
+func parseLocalizations(value string) (map[discordgo.Locale]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	localizations := make(map[discordgo.Locale]string)
+	for _, entry := range strings.Split(value, ";") {
+		locale, text, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid localization %q", entry)
+		}
+
+		localizations[discordgo.Locale(strings.TrimSpace(locale))] = text
+	}
+
+	return localizations, nil
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type schemaOptions struct {
+	Page    int                `option:"page" description:"Page" min:"1" max:"10"`
+	Query   string             `option:"query" description:"Query" required:"true" min:"2" max:"50" autocomplete:"true"`
+	Sort    string             `option:"sort" description:"Sort" choices:"New=new,Top=top"`
+	Channel *discordgo.Channel `option:"channel" description:"Channel" channels:"text, news"`
+	User    *discordgo.User    `option:"user" description:"User" required:"true" name_localizations:"es-ES=usuario" description_localizations:"es-ES=Usuario;fr=Utilisateur"`
+	Ignore  string
+}
+
+func TestGenerateOptions(t *testing.T) {
+	options, err := GenerateOptions(&schemaOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minLength, minPage := 2, 1.0
+
+	// Required options come first, in field order
+	want := []*discordgo.ApplicationCommandOption{
+		{
+			Type:         discordgo.ApplicationCommandOptionString,
+			Name:         "query",
+			Description:  "Query",
+			Required:     true,
+			Autocomplete: true,
+			MinLength:    &minLength,
+			MaxLength:    50,
+		},
+		{
+			Type:                     discordgo.ApplicationCommandOptionUser,
+			Name:                     "user",
+			Description:              "User",
+			Required:                 true,
+			NameLocalizations:        map[discordgo.Locale]string{discordgo.SpanishES: "usuario"},
+			DescriptionLocalizations: map[discordgo.Locale]string{discordgo.SpanishES: "Usuario", discordgo.French: "Utilisateur"},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "page",
+			Description: "Page",
+			MinValue:    &minPage,
+			MaxValue:    10,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "sort",
+			Description: "Sort",
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "New", Value: "new"},
+				{Name: "Top", Value: "top"},
+			},
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "Channel",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews},
+		},
+	}
+
+	if len(options) != len(want) {
+		t.Fatalf("got %d options, want %d", len(options), len(want))
+	}
+
+	for i := range want {
+		if !reflect.DeepEqual(options[i], want[i]) {
+			t.Errorf("option %d = %+v, want %+v", i, options[i], want[i])
+		}
+	}
+}
+
+func TestGenerateOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		target any
+	}{
+		{name: "not a struct", target: "query"},
+		{name: "missing description", target: struct {
+			Query string `option:"query"`
+		}{}},
+		{name: "autocomplete with choices", target: struct {
+			Sort string `option:"sort" description:"Sort" autocomplete:"true" choices:"new,top"`
+		}{}},
+		{name: "channel types on a string", target: struct {
+			Query string `option:"query" description:"Query" channels:"text"`
+		}{}},
+		{name: "unknown channel type", target: struct {
+			Channel *discordgo.Channel `option:"channel" description:"Channel" channels:"lobby"`
+		}{}},
+		{name: "invalid localization", target: struct {
+			Query string `option:"query" description:"Query" name_localizations:"es-ES"`
+		}{}},
+		{name: "invalid bound", target: struct {
+			Page int `option:"page" description:"Page" min:"one"`
+		}{}},
+		{name: "invalid choice", target: struct {
+			Page int `option:"page" description:"Page" choices:"one,two"`
+		}{}},
+		{name: "unsupported type", target: struct {
+			Tags []string `option:"tags" description:"Tags"`
+		}{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := GenerateOptions(test.target); err == nil {
+				t.Error("GenerateOptions() = nil error, want one")
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import "errors"
+
+var ErrNoDevGuild = errors.New("command is scoped to the development guild but none is configured")
+
+// Where a command is published. Commands are global unless guilds are given,
+// development commands are only published to the configured development guild.
+type CommandScope struct {
+	Guilds []string
+	Dev    bool
+}
+
+var ScopeGlobal = CommandScope{}
+var ScopeDev = CommandScope{Dev: true}
+
+func ScopeGuilds(guildIDs ...string) CommandScope {
+	return CommandScope{Guilds: guildIDs}
+}
+
+// Resolves the guilds the command must be published to, an empty result means
+// the command is global.
+func (s CommandScope) GuildIDs(devGuildID string) ([]string, error) {
+	if s.Dev {
+		if devGuildID == "" {
+			return nil, ErrNoDevGuild
+		}
+
+		return []string{devGuildID}, nil
+	}
+
+	return s.Guilds, nil
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/xid"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook url")
+
+var webhookPattern = regexp.MustCompile(`/webhooks/(\d+)/([\w-]+)`)
+
+type errorSinkKey struct{}
+
+// ErrorSink receives the reports of failed invocations. Sinks must not block
+// for long, they handle their own failures.
+type ErrorSink interface {
+	Report(c context.Context, report *ErrorReport)
+}
+
+// Forwards reports to every sink in order.
+type ErrorSinks []ErrorSink
+
+func (sinks ErrorSinks) Report(c context.Context, report *ErrorReport) {
+	for _, sink := range sinks {
+		sink.Report(c, report)
+	}
+}
+
+// Stores the sink in the context, invocations derived from it report to it.
+func WithErrorSink(c context.Context, sink ErrorSink) context.Context {
+	return context.WithValue(c, errorSinkKey{}, sink)
+}
+
+func ErrorSinkFromContext(c context.Context) (ErrorSink, bool) {
+	sink, ok := c.Value(errorSinkKey{}).(ErrorSink)
+	return sink, ok
+}
+
+// Hands the report to the sink of the context, if there is one.
+func ReportError(c context.Context, report *ErrorReport) {
+	if sink, ok := ErrorSinkFromContext(c); ok {
+		sink.Report(c, report)
+	}
+}
+
+// Logs and reports an error left unhandled by an event or task, returning
+// the ID it was reported with.
+func reportInvocationError(c context.Context, err error) xid.ID {
+	id := xid.New()
+	Logger(c).Error().Err(err).Str("error_id", id.String()).Msg("Error executing invocation not handled!")
+
+	ReportError(c, NewReport(c, id, err.Error()))
+	return id
+}
+
+// Recovers from a panic of an event or task, must be deferred directly.
+func recoverInvocation(c context.Context) {
+	if rec := recover(); rec != nil {
+		reportPanic(c, rec)
+	}
+}
+
+// Logs and reports a recovered panic, returning the ID it was reported with.
+func reportPanic(c context.Context, rec any) xid.ID {
+	id := xid.New()
+	stacktrace := debug.Stack()
+
+	Logger(c).Error().Any("panic", rec).Str("error_id", id.String()).Msg("Recovered from fatal error while executing invocation!")
+	Logger(c).Debug().Msg("Stack trace: \n" + string(stacktrace))
+
+	report := NewReport(c, id, fmt.Sprint(rec))
+	report.Panic = true
+	report.Stack = string(stacktrace)
+	ReportError(c, report)
+
+	return id
+}
+
+var _ ErrorSink = (*DiscordErrorSink)(nil)
+
+// DiscordErrorSink posts compact reports to a channel or a webhook. Repeated
+// errors are only posted once per window and at most Limit reports are posted
+// per period, so a crash loop doesn't flood the channel.
+type DiscordErrorSink struct {
+	// Identical errors (same source and message) are posted once per window
+	DedupWindow time.Duration
+	// Maximum reports posted per period, none are dropped when zero
+	Limit  int
+	Period time.Duration
+
+	session      *discordgo.Session
+	channelID    string
+	webhookID    string
+	webhookToken string
+
+	lock        sync.Mutex
+	seen        map[string]*sinkEntry
+	windowStart time.Time
+	sent        int
+	dropped     int
+}
+
+type sinkEntry struct {
+	last       time.Time
+	suppressed int
+}
+
+// Creates a sink posting to a channel the bot can write in.
+func NewChannelErrorSink(session *discordgo.Session, channelID string) *DiscordErrorSink {
+	return &DiscordErrorSink{
+		DedupWindow: 10 * time.Minute,
+		Limit:       5,
+		Period:      time.Minute,
+		session:     session,
+		channelID:   channelID,
+		seen:        make(map[string]*sinkEntry),
+	}
+}
+
+// Creates a sink posting through a webhook url.
+func NewWebhookErrorSink(session *discordgo.Session, url string) (*DiscordErrorSink, error) {
+	match := webhookPattern.FindStringSubmatch(url)
+	if match == nil {
+		return nil, ErrInvalidWebhook
+	}
+
+	sink := NewChannelErrorSink(session, "")
+	sink.webhookID, sink.webhookToken = match[1], match[2]
+
+	return sink, nil
+}
+
+func (d *DiscordErrorSink) Report(c context.Context, report *ErrorReport) {
+	suppressed, dropped, ok := d.admit(report)
+	if !ok {
+		return
+	}
+
+	message := d.createMessage(report, suppressed, dropped)
+
+	// Posting must not hold up the invocation
+	go func() {
+		if err := d.send(message); err != nil {
+			Logger(c).Warn().Err(err).Str("error_id", report.ID.String()).Msg("Failed to forward error report!")
+		}
+	}()
+}
+
+// Decides if a report is posted, returning the number of identical reports
+// suppressed since the last post and of reports dropped by the rate limit.
+func (d *DiscordErrorSink) admit(report *ErrorReport) (int, int, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%s/%s/%t/%s", report.Kind, report.Name, report.Panic, report.Message)
+
+	entry, exists := d.seen[key]
+	if exists && now.Sub(entry.last) < d.DedupWindow {
+		entry.suppressed++
+		return 0, 0, false
+	}
+
+	if d.Limit > 0 {
+		if now.Sub(d.windowStart) >= d.Period {
+			d.windowStart, d.sent = now, 0
+		}
+
+		if d.sent >= d.Limit {
+			d.dropped++
+			return 0, 0, false
+		}
+
+		d.sent++
+	}
+
+	// Forget the errors that stopped happening
+	for other, entry := range d.seen {
+		if now.Sub(entry.last) >= d.DedupWindow {
+			delete(d.seen, other)
+		}
+	}
+
+	suppressed := 0
+	if exists {
+		suppressed = entry.suppressed
+	}
+
+	d.seen[key] = &sinkEntry{last: now}
+
+	dropped := d.dropped
+	d.dropped = 0
+
+	return suppressed, dropped, true
+}
+
+func (d *DiscordErrorSink) createMessage(report *ErrorReport, suppressed, dropped int) *discordgo.WebhookParams {
+	title := "Error reported"
+	if report.Panic {
+		title = "Panic reported"
+	}
+
+	source := fmt.Sprintf("%s `%s`", report.Kind, report.Name)
+	if report.Kind == "" {
+		source = fmt.Sprintf("`%s`", report.Name)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       ColorError,
+		Title:       title,
+		Description: CodeBlock(report.Message, 1000),
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Error ID",
+				Value:  fmt.Sprintf("`%s`", report.ID),
+				Inline: true,
+			},
+			{
+				Name:   "Source",
+				Value:  source,
+				Inline: true,
+			},
+			{
+				Name:   "Server Time",
+				Value:  fmt.Sprintf("<t:%d:f>", report.Time.Unix()),
+				Inline: true,
+			},
+		},
+	}
+
+	if report.UserID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "User",
+			Value:  fmt.Sprintf("<@%s>", report.UserID),
+			Inline: true,
+		})
+	}
+
+	if report.GuildID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Guild",
+			Value:  fmt.Sprintf("`%s`", report.GuildID),
+			Inline: true,
+		})
+	}
+
+	var notes []string
+	if suppressed > 0 {
+		notes = append(notes, fmt.Sprintf("Happened %d more times since the last report", suppressed))
+	}
+
+	if dropped > 0 {
+		notes = append(notes, fmt.Sprintf("%d other reports were dropped", dropped))
+	}
+
+	if len(notes) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: strings.Join(notes, " • "),
+		}
+	}
+
+	params := &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{embed},
+	}
+
+	if report.Stack != "" {
+		params.Files = []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("st-%s.txt", report.ID),
+				ContentType: "text/plain",
+				Reader:      strings.NewReader(report.Stack),
+			},
+		}
+	}
+
+	return params
+}
+
+func (d *DiscordErrorSink) send(params *discordgo.WebhookParams) error {
+	if d.webhookID != "" {
+		_, err := d.session.WebhookExecute(d.webhookID, d.webhookToken, false, params)
+		return err
+	}
+
+	_, err := d.session.ChannelMessageSendComplex(d.channelID, &discordgo.MessageSend{
+		Embeds: params.Embeds,
+		Files:  params.Files,
+	})
+	return err
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrMissingSubcommand = errors.New("no subcommand provided")
+var ErrUnknownSubcommand = errors.New("unknown subcommand")
+
+// Subcommand handles a single subcommand of a chat input command. Middleware
+// declared here wraps only this subcommand, after the command middleware.
+type Subcommand struct {
+	Name        string
+	Description string
+	Options     []*discordgo.ApplicationCommandOption
+	Middleware  []CommandMiddleware
+	Execute     CommandExecuteFunc
+}
+
+type SubcommandGroup struct {
+	Name        string
+	Description string
+	Subcommands []Subcommand
+}
+
+// SubcommandRouter builds the option tree of a command out of its
+// subcommands and dispatches interactions to the matching handler.
+type SubcommandRouter struct {
+	Subcommands []Subcommand
+	Groups      []SubcommandGroup
+}
+
+// Returns the subcommand and subcommand group options to use in the command data.
+func (r *SubcommandRouter) Options() []*discordgo.ApplicationCommandOption {
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(r.Subcommands)+len(r.Groups))
+
+	for _, group := range r.Groups {
+		subcommands := make([]*discordgo.ApplicationCommandOption, 0, len(group.Subcommands))
+		for _, sub := range group.Subcommands {
+			subcommands = append(subcommands, sub.Option())
+		}
+
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        group.Name,
+			Description: group.Description,
+			Options:     subcommands,
+		})
+	}
+
+	for _, sub := range r.Subcommands {
+		options = append(options, sub.Option())
+	}
+
+	return options
+}
+
+// Runs the subcommand invoked by the interaction, the command is handed to the
+// subcommand middleware.
+func (r *SubcommandRouter) Execute(command Command, c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	sub, err := r.Find(i.ApplicationCommandData().Options)
+	if err != nil {
+		return err
+	}
+
+	next := sub.Execute
+
+	// Execute the middleware in reverse order
+	// to ensure the first middleware is executed last
+	for i := len(sub.Middleware) - 1; i >= 0; i-- {
+		mw := sub.Middleware[i]
+		next = mw.Handle(command, next)
+	}
+
+	return next(c, s, i)
+}
+
+// Finds the subcommand matching the interaction options.
+func (r *SubcommandRouter) Find(options []*discordgo.ApplicationCommandInteractionDataOption) (*Subcommand, error) {
+	if len(options) == 0 {
+		return nil, ErrMissingSubcommand
+	}
+
+	option := options[0]
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionSubCommandGroup:
+		for _, group := range r.Groups {
+			if group.Name != option.Name {
+				continue
+			}
+
+			if len(option.Options) == 0 {
+				return nil, ErrMissingSubcommand
+			}
+
+			return findSubcommand(group.Subcommands, option.Options[0])
+		}
+	case discordgo.ApplicationCommandOptionSubCommand:
+		return findSubcommand(r.Subcommands, option)
+	default:
+		return nil, ErrMissingSubcommand
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownSubcommand, option.Name)
+}
+
+// Returns the option of the subcommand as it should be published.
+func (sub Subcommand) Option() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        sub.Name,
+		Description: sub.Description,
+		Options:     sub.Options,
+	}
+}
+
+// Returns the options passed to the invoked subcommand, descending into
+// subcommand groups. Commands without subcommands return their own options.
+func SubcommandOptions(options []*discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandInteractionDataOption {
+	for len(options) > 0 {
+		switch options[0].Type {
+		case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+			options = options[0].Options
+		default:
+			return options
+		}
+	}
+
+	return options
+}
+
+func findSubcommand(subcommands []Subcommand, option *discordgo.ApplicationCommandInteractionDataOption) (*Subcommand, error) {
+	if option.Type != discordgo.ApplicationCommandOptionSubCommand {
+		return nil, ErrMissingSubcommand
+	}
+
+	for i := range subcommands {
+		if subcommands[i].Name == option.Name {
+			return &subcommands[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownSubcommand, option.Name)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Changes required to make the commands registered on Discord match the local ones
+type CommandDiff struct {
+	Create    []*discordgo.ApplicationCommand
+	Update    []*discordgo.ApplicationCommand
+	Delete    []*discordgo.ApplicationCommand
+	Unchanged []*discordgo.ApplicationCommand
+}
+
+func (d CommandDiff) Empty() bool {
+	return len(d.Create) == 0 && len(d.Update) == 0 && len(d.Delete) == 0
+}
+
+// Compares local command definitions with the ones registered on Discord.
+func DiffCommands(local, remote []*discordgo.ApplicationCommand) CommandDiff {
+	var diff CommandDiff
+
+	registered := make(map[CommandKey]*discordgo.ApplicationCommand, len(remote))
+	for _, cmd := range remote {
+		registered[NewCommandKey(*cmd)] = cmd
+	}
+
+	for _, cmd := range local {
+		key := NewCommandKey(*cmd)
+
+		existing, exists := registered[key]
+		delete(registered, key)
+
+		switch {
+		case !exists:
+			diff.Create = append(diff.Create, cmd)
+		case !CommandsEqual(cmd, existing):
+			diff.Update = append(diff.Update, cmd)
+		default:
+			diff.Unchanged = append(diff.Unchanged, cmd)
+		}
+	}
+
+	for _, cmd := range registered {
+		diff.Delete = append(diff.Delete, cmd)
+	}
+
+	// Keep the plan stable between runs
+	slices.SortFunc(diff.Delete, func(a, b *discordgo.ApplicationCommand) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return diff
+}
+
+// Reports whether two command definitions would be registered identically,
+// fields filled in by Discord (IDs, version, defaults) are ignored.
+func CommandsEqual(a, b *discordgo.ApplicationCommand) bool {
+	left, err := json.Marshal(normalizeCommand(*a))
+	if err != nil {
+		return false
+	}
+
+	right, err := json.Marshal(normalizeCommand(*b))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(left, right)
+}
+
+func normalizeCommand(cmd discordgo.ApplicationCommand) discordgo.ApplicationCommand {
+	var dmPermission, nsfw = true, false
+
+	cmd.ID = ""
+	cmd.ApplicationID = ""
+	cmd.GuildID = ""
+	cmd.Version = ""
+	cmd.DefaultPermission = nil
+
+	if cmd.Type == 0 {
+		cmd.Type = discordgo.ChatApplicationCommand
+	}
+
+	if cmd.DMPermission == nil {
+		cmd.DMPermission = &dmPermission
+	}
+
+	if cmd.NSFW == nil {
+		cmd.NSFW = &nsfw
+	}
+
+	if cmd.NameLocalizations != nil && len(*cmd.NameLocalizations) == 0 {
+		cmd.NameLocalizations = nil
+	}
+
+	if cmd.DescriptionLocalizations != nil && len(*cmd.DescriptionLocalizations) == 0 {
+		cmd.DescriptionLocalizations = nil
+	}
+
+	cmd.Options = normalizeOptions(cmd.Options)
+	return cmd
+}
+
+func normalizeOptions(options []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(options) == 0 {
+		return nil
+	}
+
+	normalized := make([]*discordgo.ApplicationCommandOption, 0, len(options))
+	for _, option := range options {
+		copied := *option
+
+		if len(copied.NameLocalizations) == 0 {
+			copied.NameLocalizations = nil
+		}
+
+		if len(copied.DescriptionLocalizations) == 0 {
+			copied.DescriptionLocalizations = nil
+		}
+
+		if len(copied.ChannelTypes) == 0 {
+			copied.ChannelTypes = nil
+		}
+
+		if len(copied.Choices) == 0 {
+			copied.Choices = nil
+		}
+
+		copied.Options = normalizeOptions(copied.Options)
+		normalized = append(normalized, &copied)
+	}
+
+	return normalized
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func remoteCommand(t *testing.T, raw string) *discordgo.ApplicationCommand {
+	t.Helper()
+
+	var cmd discordgo.ApplicationCommand
+	if err := json.Unmarshal([]byte(raw), &cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	return &cmd
+}
+
+func TestCommandsEqual(t *testing.T) {
+	localized := map[discordgo.Locale]string{discordgo.SpanishES: "hola"}
+	empty := map[discordgo.Locale]string{}
+
+	tests := []struct {
+		name   string
+		local  *discordgo.ApplicationCommand
+		remote string
+		equal  bool
+	}{
+		{
+			name:   "fields filled in by discord are ignored",
+			local:  &discordgo.ApplicationCommand{Name: "ping", Description: "Pong!"},
+			remote: `{"id":"1","application_id":"2","guild_id":"3","version":"4","type":1,"name":"ping","description":"Pong!","dm_permission":true,"nsfw":false,"default_permission":true,"options":null}`,
+			equal:  true,
+		},
+		{
+			name:   "localizations are compared",
+			local:  &discordgo.ApplicationCommand{Name: "ping", Description: "Pong!", NameLocalizations: &localized},
+			remote: `{"id":"1","type":1,"name":"ping","name_localizations":{"es-ES":"hola"},"description":"Pong!"}`,
+			equal:  true,
+		},
+		{
+			name:   "changed localizations are detected",
+			local:  &discordgo.ApplicationCommand{Name: "ping", Description: "Pong!", NameLocalizations: &localized},
+			remote: `{"id":"1","type":1,"name":"ping","name_localizations":{"es-ES":"adios"},"description":"Pong!"}`,
+			equal:  false,
+		},
+		{
+			name:   "empty localizations match missing ones",
+			local:  &discordgo.ApplicationCommand{Name: "ping", Description: "Pong!", DescriptionLocalizations: &empty},
+			remote: `{"id":"1","type":1,"name":"ping","description":"Pong!","description_localizations":null}`,
+			equal:  true,
+		},
+		{
+			name: "nested options are normalized",
+			local: &discordgo.ApplicationCommand{Name: "yiff", Description: "Yiff", Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "random", Description: "Random"},
+			}},
+			remote: `{"id":"1","type":1,"name":"yiff","description":"Yiff","options":[{"type":1,"name":"random","description":"Random","options":[],"choices":[],"channel_types":[]}]}`,
+			equal:  true,
+		},
+		{
+			name:   "changed descriptions are detected",
+			local:  &discordgo.ApplicationCommand{Name: "ping", Description: "Pong!"},
+			remote: `{"id":"1","type":1,"name":"ping","description":"Ping!"}`,
+			equal:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := CommandsEqual(test.local, remoteCommand(t, test.remote)); equal != test.equal {
+				t.Errorf("CommandsEqual() = %v, want %v", equal, test.equal)
+			}
+		})
+	}
+}
+
+func TestDiffCommands(t *testing.T) {
+	local := []*discordgo.ApplicationCommand{
+		{Name: "ping", Description: "Pong!"},
+		{Name: "tasks", Description: "Tasks, now with more options"},
+		{Name: "new", Description: "Brand new"},
+		{Name: "source", Type: discordgo.MessageApplicationCommand},
+	}
+
+	remote := []*discordgo.ApplicationCommand{
+		remoteCommand(t, `{"id":"1","type":1,"name":"ping","description":"Pong!"}`),
+		remoteCommand(t, `{"id":"2","type":1,"name":"tasks","description":"Tasks"}`),
+		remoteCommand(t, `{"id":"3","type":1,"name":"old","description":"Gone"}`),
+		remoteCommand(t, `{"id":"4","type":1,"name":"source","description":"Slash command with the same name"}`),
+	}
+
+	diff := DiffCommands(local, remote)
+
+	tests := []struct {
+		name     string
+		commands []*discordgo.ApplicationCommand
+		want     []string
+	}{
+		{name: "create", commands: diff.Create, want: []string{"new", "source"}},
+		{name: "update", commands: diff.Update, want: []string{"tasks"}},
+		{name: "delete", commands: diff.Delete, want: []string{"old", "source"}},
+		{name: "unchanged", commands: diff.Unchanged, want: []string{"ping"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, cmd := range test.commands {
+				names = append(names, cmd.Name)
+			}
+
+			if !slices.Equal(names, test.want) {
+				t.Errorf("got %v, want %v", names, test.want)
+			}
+		})
+	}
+
+	if diff.Empty() {
+		t.Error("Empty() = true for a diff with changes")
+	}
+
+	if !DiffCommands(local[:1], remote[:1]).Empty() {
+		t.Error("Empty() = false for matching commands")
+	}
+}
@@ -3,9 +3,15 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/robfig/cron"
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,7 +25,19 @@ type TaskStack struct {
 
 type TaskData struct {
 	Name string
+	// Standard 5 field cron spec, 6 fields with leading seconds or a
+	// descriptor, see ParseSchedule
 	Cron string
+	// IANA timezone the spec is evaluated in, local time when empty
+	Timezone string
+	// Runs missed while the bot was down, skipped by default
+	CatchUp CatchUpPolicy
+	// Runs due while the task is running, skipped by default
+	Overlap OverlapPolicy
+
+	// Maximum duration of a run, the task context is cancelled once it is
+	// exceeded. Overrides the default timeout of the manager when positive.
+	Timeout time.Duration
 }
 
 type Task interface {
@@ -34,81 +52,437 @@ type TaskMiddleware interface {
 type TaskManager interface {
 	PublishTasks(session *discordgo.Session) error
 	RegisterStack(stack TaskStack) error
+	RegisterJobHandler(name string, handler JobHandler) error
+}
+
+var (
+	ErrUnknownTask       = errors.New("unknown task")
+	ErrTasksNotPublished = errors.New("tasks are not published yet")
+)
+
+// TaskInfo is a snapshot of the state of a scheduled task.
+type TaskInfo struct {
+	Data    TaskData
+	Next    time.Time
+	Paused  bool
+	LastRun *TaskRun
+	// Whether the task was added at runtime, see JobScheduler
+	Job bool
 }
 
+// TaskController inspects and controls the published tasks.
+type TaskController interface {
+	// Returns the registered tasks sorted by name
+	ListTasks() []TaskInfo
+	// Runs a task right away through its middleware and waits for it
+	RunTask(name string) (TaskRun, error)
+	// Paused tasks skip their scheduled runs, they can still be run manually
+	PauseTask(name string) error
+	ResumeTask(name string) error
+}
+
+var _ TaskController = (*TaskManagerImpl)(nil)
+var _ JobScheduler = (*TaskManagerImpl)(nil)
+
 type TaskManagerImpl struct {
-	cron  *cron.Cron
-	tasks map[string]TaskStack
+	// Default execution timeout of tasks, none when zero
+	Timeout time.Duration
+
+	// Where runs are recorded, missed runs are not caught up without it
+	History TaskHistoryStore
+
+	// Where persisted jobs are kept, jobs only live in memory without it
+	Jobs JobStore
+
+	// Applied to jobs added at runtime, module tasks get theirs from the
+	// ModuleManager
+	JobMiddleware []TaskMiddleware
+
+	root context.Context
+	cron *cron.Cron
+
+	// Guards everything below, tasks and jobs change while running
+	lock     sync.Mutex
+	session  *discordgo.Session
+	tasks    map[string]*scheduledTask
+	handlers map[string]JobHandler
+}
+
+// A task known to the manager, registered by a module or added as a job.
+type scheduledTask struct {
+	stack    TaskStack
+	data     TaskData
+	schedule cron.Schedule
+	execute  TaskExecuteFunc
+	entry    cron.EntryID
+
+	// Held by the running runs, see OverlapPolicy
+	running sync.Mutex
+	paused  bool
+	lastRun *TaskRun
+
+	// Only set for jobs, removed jobs never run again (not even the runs
+	// already fired when they were taken out of the scheduler)
+	job     *Job
+	removed atomic.Bool
 }
 
-func NewTaskManager() *TaskManagerImpl {
+func NewTaskManager(root context.Context) *TaskManagerImpl {
 	return &TaskManagerImpl{
-		cron:  cron.New(),
-		tasks: make(map[string]TaskStack),
+		root:     root,
+		cron:     newCron(),
+		tasks:    make(map[string]*scheduledTask),
+		handlers: make(map[string]JobHandler),
 	}
 }
 
 func (tm *TaskManagerImpl) RegisterStack(stack TaskStack) error {
 	data := stack.Task.Data()
 
+	// Bad specs are rejected before anything gets scheduled
+	schedule, err := ParseSchedule(data.Cron, data.Timezone)
+	if err != nil {
+		return fmt.Errorf("task %q: %w", data.Name, err)
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
 	if _, exists := tm.tasks[data.Name]; exists {
 		return errors.New("task already registered")
 	}
 
-	tm.tasks[data.Name] = stack
+	tm.tasks[data.Name] = &scheduledTask{
+		stack:    stack,
+		data:     data,
+		schedule: schedule,
+	}
+
 	return nil
 }
 
 func (tm *TaskManagerImpl) PublishTasks(session *discordgo.Session) error {
-	type GeneratedTask struct {
-		data    TaskData
-		execute TaskExecuteFunc
+	tm.lock.Lock()
+	tm.session = session
+
+	for _, task := range tm.tasks {
+		if task.job != nil {
+			tm.scheduleJob(task)
+			continue
+		}
+
+		tm.scheduleTask(task)
+
+		// Catch up in the background, the scheduler starts right away
+		if missed := tm.missedRuns(task); len(missed) > 0 {
+			go tm.catchUp(task, missed)
+		}
 	}
+	tm.lock.Unlock()
 
-	var publishChannel = make(chan GeneratedTask, len(tm.tasks))
+	tm.restoreJobs()
+
+	// Start the cron scheduler
+	tm.cron.Start()
+	log.Info().Msg("Cron scheduler started!")
 
+	// Stop scheduling new runs once the bot shuts down
 	go func() {
-		defer close(publishChannel)
+		<-tm.root.Done()
+		tm.cron.Stop()
+		log.Info().Msg("Cron scheduler stopped!")
+	}()
+
+	return nil
+}
 
-		for _, stack := range tm.tasks {
-			data := stack.Task.Data()
+// Compiles a task and hands it to the scheduler, the lock must be held.
+func (tm *TaskManagerImpl) scheduleTask(task *scheduledTask) {
+	name := task.data.Name
+	task.execute = task.stack.Compile()
 
-			// Register the task with the middleware
-			next := func(c context.Context, s *discordgo.Session) error {
-				return stack.Task.Run(c, s)
-			}
+	task.entry = tm.cron.Schedule(task.schedule, cron.FuncJob(func() {
+		// Runs are fired on the second they were scheduled for
+		tm.fire(task, time.Now().Truncate(time.Second))
+	}))
 
-			// Execute the middleware in reverse order
-			// to ensure the first middleware is executed last
-			for i := len(stack.Middleware) - 1; i >= 0; i-- {
-				mw := stack.Middleware[i]
-				next = mw.Handle(stack.Task, next)
-			}
+	next := task.schedule.Next(time.Now())
+	if next.IsZero() {
+		log.Info().Msgf("Scheduled task %q (%q), it will not run again", name, task.data.Cron)
+		return
+	}
+
+	log.Info().Msgf("Scheduled task %q (%q), next run at %s", name, task.data.Cron, next.Format(time.RFC3339))
+}
+
+// Runs a task on behalf of the scheduler.
+func (tm *TaskManagerImpl) fire(task *scheduledTask, scheduled time.Time) {
+	name := task.data.Name
+
+	if task.removed.Load() {
+		return
+	}
+
+	tm.lock.Lock()
+	paused := task.paused
+	tm.lock.Unlock()
+
+	if paused {
+		log.Debug().Msgf("Skipping run of paused task %q", name)
+		return
+	}
+
+	tm.run(task, scheduled)
+
+	// One-shot jobs are done after their run
+	if task.job != nil && !task.job.At.IsZero() {
+		if err := tm.removeJob(task); err != nil {
+			log.Warn().Err(err).Msgf("Failed to remove one-shot job %q", name)
+		}
+		return
+	}
+
+	if next := task.schedule.Next(time.Now()); !next.IsZero() {
+		log.Debug().Msgf("Next run of task %q at %s", name, next.Format(time.RFC3339))
+	}
+}
+
+// Returns the scheduled times of a task since its last recorded run that
+// should be caught up according to its policy.
+func (tm *TaskManagerImpl) missedRuns(task *scheduledTask) []time.Time {
+	data := task.data
+	if tm.History == nil || data.CatchUp == CatchUpSkip {
+		return nil
+	}
+
+	record, err := tm.History.Get(data.Name)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to read the history of task %q", data.Name)
+		return nil
+	}
+
+	// Tasks that never ran have nothing to catch up
+	if record.LastRun == nil {
+		return nil
+	}
+
+	var missed []time.Time
+	now := time.Now()
+
+	for next := task.schedule.Next(record.LastRun.Scheduled); next.Before(now); next = task.schedule.Next(next) {
+		// Schedules that never fire again (like one-shot jobs) return the zero
+		// time, which is always in the past and would be caught up forever
+		if next.IsZero() {
+			break
+		}
+
+		// A single run stands for all the missed ones, the latest of them
+		if data.CatchUp == CatchUpOnce {
+			missed = append(missed[:0], next)
+			continue
+		}
+
+		if len(missed) == MaxCatchUpRuns {
+			log.Warn().Msgf("Task %q missed more than %d runs, only the first are caught up", data.Name, MaxCatchUpRuns)
+			break
+		}
 
-			publishChannel <- GeneratedTask{
-				data:    data,
-				execute: next,
+		missed = append(missed, next)
+	}
+
+	return missed
+}
+
+func (tm *TaskManagerImpl) catchUp(task *scheduledTask, missed []time.Time) {
+	log.Info().Msgf("Catching up %d missed runs of task %q", len(missed), task.data.Name)
+
+	for _, scheduled := range missed {
+		if tm.root.Err() != nil {
+			return
+		}
+
+		tm.run(task, scheduled)
+	}
+}
+
+// Executes a task once in its own invocation and records the outcome.
+func (tm *TaskManagerImpl) run(task *scheduledTask, scheduled time.Time) TaskRun {
+	data := task.data
+
+	// Held for the whole run unless overlapping runs are allowed
+	switch data.Overlap {
+	case OverlapSkip:
+		if !task.running.TryLock() {
+			log.Warn().Str("outcome", string(OutcomeSkipped)).Msgf("Skipping run of task %q, the previous run is still going", data.Name)
+
+			now := time.Now()
+			run := TaskRun{Scheduled: scheduled, Started: now, Finished: now, Outcome: OutcomeSkipped}
+			tm.record(task, run)
+			return run
+		}
+		defer task.running.Unlock()
+	case OverlapQueue:
+		task.running.Lock()
+		defer task.running.Unlock()
+	}
+
+	log.Debug().Msgf("Running task %q", data.Name)
+
+	timeout := data.Timeout
+	if timeout <= 0 {
+		timeout = tm.Timeout
+	}
+
+	c, cancel := NewInvocationContext(tm.root, Invocation{
+		Kind: "task",
+		Name: data.Name,
+	}, timeout)
+	defer cancel()
+
+	tm.lock.Lock()
+	session, execute := tm.session, task.execute
+	tm.lock.Unlock()
+
+	run := TaskRun{
+		Scheduled: scheduled,
+		Started:   time.Now(),
+	}
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				run.Outcome = OutcomePanic
+				run.Error = fmt.Sprint(rec)
+				run.ErrorID = reportPanic(c, rec).String()
 			}
+		}()
+
+		err := execute(c, session)
+
+		run.Outcome = OutcomeOf(err)
+		if err != nil {
+			run.Error = err.Error()
+			run.ErrorID = reportInvocationError(c, err).String()
 		}
 	}()
 
-	for task := range publishChannel {
-		tm.cron.AddFunc(task.data.Cron, func() {
-			log.Debug().Msgf("Scheduling task %q with cron expression %q", task.data.Name, task.data.Cron)
+	run.Finished = time.Now()
 
-			if err := task.execute(context.Background(), session); err != nil {
-				log.Error().Err(err).Msgf("Error executing task %q not handled!", task.data.Name)
-			}
+	// Tasks ignoring their context still count as timed out
+	if errors.Is(c.Err(), context.DeadlineExceeded) {
+		run.Outcome = OutcomeTimeout
+		Logger(c).Warn().Msgf("Task %q exceeded its maximum duration of %s", data.Name, timeout)
+	}
+
+	Logger(c).Info().
+		Str("outcome", string(run.Outcome)).
+		Dur("duration", run.Finished.Sub(run.Started)).
+		Msgf("Task %q finished", data.Name)
+
+	tm.record(task, run)
+	return run
+}
+
+func (tm *TaskManagerImpl) record(task *scheduledTask, run TaskRun) {
+	tm.lock.Lock()
+	task.lastRun = &run
+	tm.lock.Unlock()
+
+	if tm.History != nil {
+		if err := tm.History.Record(task.data.Name, run); err != nil {
+			log.Warn().Err(err).Msgf("Failed to record the run of task %q", task.data.Name)
+		}
+	}
+}
+
+func (tm *TaskManagerImpl) ListTasks() []TaskInfo {
+	tm.lock.Lock()
+	infos := make([]TaskInfo, 0, len(tm.tasks))
+	for _, task := range tm.tasks {
+		infos = append(infos, TaskInfo{
+			Data:    task.data,
+			Next:    task.schedule.Next(time.Now()),
+			Paused:  task.paused,
+			LastRun: task.lastRun,
+			Job:     task.job != nil,
 		})
 	}
+	tm.lock.Unlock()
 
-	// Start the cron scheduler
-	tm.cron.Start()
-	log.Info().Msg("Cron scheduler started!")
+	// Runs from before a restart are only known to the history
+	for i, info := range infos {
+		if info.LastRun != nil || tm.History == nil {
+			continue
+		}
+
+		if record, err := tm.History.Get(info.Data.Name); err == nil {
+			infos[i].LastRun = record.LastRun
+		}
+	}
+
+	slices.SortFunc(infos, func(a, b TaskInfo) int {
+		return strings.Compare(a.Data.Name, b.Data.Name)
+	})
+
+	return infos
+}
+
+func (tm *TaskManagerImpl) RunTask(name string) (TaskRun, error) {
+	tm.lock.Lock()
+	task, exists := tm.tasks[name]
+	published := tm.session != nil
+	tm.lock.Unlock()
+
+	if !exists {
+		return TaskRun{}, ErrUnknownTask
+	}
+
+	if !published {
+		return TaskRun{}, ErrTasksNotPublished
+	}
 
+	return tm.run(task, time.Now().Truncate(time.Second)), nil
+}
+
+func (tm *TaskManagerImpl) PauseTask(name string) error {
+	return tm.setPaused(name, true)
+}
+
+func (tm *TaskManagerImpl) ResumeTask(name string) error {
+	return tm.setPaused(name, false)
+}
+
+func (tm *TaskManagerImpl) setPaused(name string, paused bool) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+
+	task, exists := tm.tasks[name]
+	if !exists {
+		return ErrUnknownTask
+	}
+
+	task.paused = paused
+
+	log.Info().Msgf("Task %q paused: %t", name, paused)
 	return nil
 }
 
+// Wraps the task with its middleware into a single execute function.
+func (stack TaskStack) Compile() TaskExecuteFunc {
+	next := func(c context.Context, s *discordgo.Session) error {
+		return stack.Task.Run(c, s)
+	}
+
+	// Execute the middleware in reverse order
+	// to ensure the first middleware is executed last
+	for i := len(stack.Middleware) - 1; i >= 0; i-- {
+		mw := stack.Middleware[i]
+		next = mw.Handle(stack.Task, next)
+	}
+
+	return next
+}
+
 func CompileTasks(task Task, middleware ...TaskMiddleware) TaskStack {
 	return TaskStack{
 		Task:       task,
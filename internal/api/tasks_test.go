@@ -0,0 +1,87 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+type memoryHistory map[string]*TaskRecord
+
+func (m memoryHistory) Record(name string, run TaskRun) error {
+	m[name] = &TaskRecord{Name: name, LastRun: &run}
+	return nil
+}
+
+func (m memoryHistory) Get(name string) (*TaskRecord, error) {
+	if record, ok := m[name]; ok {
+		return record, nil
+	}
+
+	return &TaskRecord{Name: name}, nil
+}
+
+func TestMissedRuns(t *testing.T) {
+	schedule, err := ParseSchedule("@every 1h", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	// Last ran the given hours ago, half an hour before the next run is due
+	lastRun := func(hours int) *TaskRun {
+		return &TaskRun{Scheduled: now.Add(-time.Duration(hours)*time.Hour - 30*time.Minute)}
+	}
+
+	tests := []struct {
+		name    string
+		catchUp CatchUpPolicy
+		lastRun *TaskRun
+		count   int
+		// Hours since the last run of the first and last missed run
+		first, last int
+	}{
+		{name: "skip", catchUp: CatchUpSkip, lastRun: lastRun(3)},
+		{name: "never ran", catchUp: CatchUpAll},
+		{name: "nothing missed", catchUp: CatchUpAll, lastRun: lastRun(0)},
+		{name: "all", catchUp: CatchUpAll, lastRun: lastRun(3), count: 3, first: 1, last: 3},
+		{name: "all is capped", catchUp: CatchUpAll, lastRun: lastRun(MaxCatchUpRuns * 2), count: MaxCatchUpRuns, first: 1, last: MaxCatchUpRuns},
+		{name: "once", catchUp: CatchUpOnce, lastRun: lastRun(3), count: 1, first: 3, last: 3},
+		{name: "once is not capped", catchUp: CatchUpOnce, lastRun: lastRun(MaxCatchUpRuns * 2), count: 1, first: MaxCatchUpRuns * 2, last: MaxCatchUpRuns * 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			history := memoryHistory{}
+			if test.lastRun != nil {
+				history.Record("task", *test.lastRun)
+			}
+
+			tm := &TaskManagerImpl{History: history}
+			missed := tm.missedRuns(&scheduledTask{
+				data:     TaskData{Name: "task", CatchUp: test.catchUp},
+				schedule: schedule,
+			})
+
+			if len(missed) != test.count {
+				t.Fatalf("got %d missed runs, want %d", len(missed), test.count)
+			}
+
+			if test.count == 0 {
+				return
+			}
+
+			at := func(hours int) time.Time {
+				return test.lastRun.Scheduled.Add(time.Duration(hours) * time.Hour)
+			}
+
+			if first := missed[0]; !first.Equal(at(test.first)) {
+				t.Errorf("first missed run at %s, want %s", first, at(test.first))
+			}
+
+			if last := missed[len(missed)-1]; !last.Equal(at(test.last)) {
+				t.Errorf("last missed run at %s, want %s", last, at(test.last))
+			}
+		})
+	}
+}
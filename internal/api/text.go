@@ -0,0 +1,28 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Shortens the text to at most limit characters, ending it with "..." when
+// cut. Discord counts characters, so runes are never split.
+func Truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	if limit <= 3 {
+		return strings.Repeat(".", max(limit, 0))
+	}
+
+	runes := []rune(text)
+	return string(runes[:limit-3]) + "..."
+}
+
+// Wraps the text truncated to limit characters in a code block, breaking up
+// fences inside it.
+func CodeBlock(text string, limit int) string {
+	return fmt.Sprintf("```\n%s\n```", strings.ReplaceAll(Truncate(text, limit), "```", "'''"))
+}
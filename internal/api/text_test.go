@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{text: "short", limit: 10, want: "short"},
+		{text: "exactly", limit: 7, want: "exactly"},
+		{text: "too long text", limit: 8, want: "too l..."},
+		{text: "ñañañaña", limit: 6, want: "ñañ..."},
+		{text: "🦊🦊🦊🦊", limit: 4, want: "🦊🦊🦊🦊"},
+		{text: "🦊🦊🦊🦊🦊", limit: 4, want: "🦊..."},
+		{text: "anything", limit: 2, want: ".."},
+	}
+
+	for _, test := range tests {
+		if got := Truncate(test.text, test.limit); got != test.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	if got, want := CodeBlock("a ```b``` c", 100), "```\na '''b''' c\n```"; got != want {
+		t.Errorf("CodeBlock() = %q, want %q", got, want)
+	}
+}
@@ -7,13 +7,53 @@ import (
 
 type Config struct {
 	BotToken string `json:"bot_token"`
-	Colors   struct {
+	// Path of the embedded database, defaults to data/twotto.db
+	Database string `json:"database"`
+	// Users allowed to run owner-only commands, the first one is shown as contact
+	Owners     []string `json:"owners"`
+	AdminRoles []string `json:"admin_roles"`
+	Colors     struct {
 		Info    int `json:"info"`
 		Result  int `json:"result"`
 		Success int `json:"success"`
 		Warning int `json:"warning"`
 		Error   int `json:"error"`
 	} `json:"colors"`
+	Commands struct {
+		DryRun     bool   `json:"dry_run"`
+		DevGuildID string `json:"dev_guild_id"`
+		// Milliseconds before a command is deferred automatically
+		DeferAfter int `json:"defer_after"`
+	} `json:"commands"`
+	// Where error reports are forwarded, the webhook is used when both are set
+	Errors struct {
+		ChannelID string `json:"channel_id"`
+		Webhook   string `json:"webhook"`
+	} `json:"errors"`
+	// Default execution timeouts in seconds, none when zero
+	Timeouts struct {
+		Commands int `json:"commands"`
+		Events   int `json:"events"`
+		Tasks    int `json:"tasks"`
+	} `json:"timeouts"`
+}
+
+// Returns the user ID shown to users as contact when something goes wrong.
+func (c Config) Contact() string {
+	if len(c.Owners) == 0 {
+		return ""
+	}
+
+	return c.Owners[0]
+}
+
+// Returns the path of the embedded database.
+func (c Config) DatabasePath() string {
+	if c.Database == "" {
+		return "data/twotto.db"
+	}
+
+	return c.Database
 }
 
 type ConfigProvider interface {
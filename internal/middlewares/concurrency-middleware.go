@@ -0,0 +1,282 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.CommandMiddleware = (*ConcurrencyMiddleware)(nil)
+
+// ConcurrencyMiddleware caps how many executions of each command run at once,
+// globally and per guild. Excess executions either wait in a queue or are
+// rejected.
+type ConcurrencyMiddleware struct {
+	logger   zerolog.Logger
+	limit    int
+	perGuild int
+	queue    bool
+}
+
+// Creates the middleware, a per guild limit of zero disables it.
+func NewConcurrencyMiddleware(parent zerolog.Logger, limit, perGuild int, queue bool) *ConcurrencyMiddleware {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &ConcurrencyMiddleware{
+		logger:   parent.With().Str("middleware", "concurrency").Logger(),
+		limit:    limit,
+		perGuild: perGuild,
+		queue:    queue,
+	}
+}
+
+func (m *ConcurrencyMiddleware) Handle(command api.Command, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	name := command.Data().Name
+
+	ephemeral := false
+	if preference, ok := command.(api.EphemeralCommand); ok {
+		ephemeral = preference.Ephemeral()
+	}
+
+	// Every command gets its own limiter
+	limiter := &concurrencyLimiter{
+		limit:    m.limit,
+		perGuild: m.perGuild,
+		guilds:   make(map[string]int),
+	}
+
+	return func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+		responder := api.GetResponder(c, s, i)
+
+		key := m.Key(i)
+
+		ticket, started := limiter.Acquire(key, m.queue)
+		if ticket == nil && !started {
+			return responder.Reply(&discordgo.InteractionResponseData{
+				Flags:  discordgo.MessageFlagsEphemeral,
+				Embeds: []*discordgo.MessageEmbed{m.CreateBusyEmbed()},
+			})
+		}
+
+		if !started {
+			m.logger.Debug().Msgf("Queued execution of command \"%s\"", name)
+
+			if err := m.Wait(c, limiter, ticket, responder, ephemeral); err != nil {
+				return err
+			}
+		}
+		defer limiter.Release(key)
+
+		return next(c, s, i)
+	}
+}
+
+// Returns the key the per guild limit is counted under.
+func (m *ConcurrencyMiddleware) Key(i *discordgo.InteractionCreate) string {
+	// Direct messages are limited per user
+	if i.GuildID == "" {
+		return api.InteractionUser(i).ID
+	}
+
+	return i.GuildID
+}
+
+// Waits for the ticket to start while keeping the queue position updated.
+func (m *ConcurrencyMiddleware) Wait(c context.Context, limiter *concurrencyLimiter, ticket *concurrencyTicket, responder *api.Responder, ephemeral bool) error {
+	var flags discordgo.MessageFlags
+	if ephemeral {
+		flags |= discordgo.MessageFlagsEphemeral
+	}
+
+	position := limiter.Position(ticket)
+	if err := responder.Reply(&discordgo.InteractionResponseData{
+		Flags:  flags,
+		Embeds: []*discordgo.MessageEmbed{m.CreateQueueEmbed(position)},
+	}); err != nil {
+		m.logger.Warn().Err(err).Msg("Failed to send queue status!")
+	}
+
+	for {
+		select {
+		case <-ticket.ready:
+			// Don't leave the queue position behind, commands replying
+			// instead of editing send their output as a follow-up
+			if _, err := responder.Edit(&discordgo.WebhookEdit{
+				Embeds: &[]*discordgo.MessageEmbed{m.CreateStartingEmbed()},
+			}); err != nil {
+				m.logger.Warn().Err(err).Msg("Failed to update queue status!")
+			}
+
+			return nil
+		case <-ticket.moved:
+			updated := limiter.Position(ticket)
+			if updated == position || updated == 0 {
+				continue
+			}
+
+			position = updated
+			if _, err := responder.Edit(&discordgo.WebhookEdit{
+				Embeds: &[]*discordgo.MessageEmbed{m.CreateQueueEmbed(position)},
+			}); err != nil {
+				m.logger.Warn().Err(err).Msg("Failed to update queue status!")
+			}
+		case <-c.Done():
+			// The ticket might have started right before leaving
+			if !limiter.Cancel(ticket) {
+				limiter.Release(ticket.guildID)
+			}
+
+			return c.Err()
+		}
+	}
+}
+
+func (m *ConcurrencyMiddleware) CreateStartingEmbed() *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       "Starting...",
+		Color:       api.ColorInfo,
+		Description: "Your turn came up, the command is starting now.",
+	}
+}
+
+func (m *ConcurrencyMiddleware) CreateQueueEmbed(position int) *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       "Waiting in queue...",
+		Color:       api.ColorInfo,
+		Description: fmt.Sprintf("This command is busy right now, you are **#%d** in queue. It will start automatically.", position),
+	}
+}
+
+func (m *ConcurrencyMiddleware) CreateBusyEmbed() *discordgo.MessageEmbed {
+	return &discordgo.MessageEmbed{
+		Title:       "Busy! >.<",
+		Color:       api.ColorWarning,
+		Description: "This command is already running too many times, try again in a moment.",
+	}
+}
+
+type concurrencyTicket struct {
+	guildID string
+	ready   chan struct{}
+	moved   chan struct{}
+}
+
+type concurrencyLimiter struct {
+	limit    int
+	perGuild int
+
+	lock    sync.Mutex
+	running int
+	guilds  map[string]int
+	waiting []*concurrencyTicket
+}
+
+// Starts an execution right away when possible, otherwise queues a ticket
+// (when queueing is enabled) that is readied once a slot frees up.
+func (l *concurrencyLimiter) Acquire(guildID string, queue bool) (*concurrencyTicket, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Executions never skip tickets of the same guild already waiting
+	waiting := slices.ContainsFunc(l.waiting, func(ticket *concurrencyTicket) bool {
+		return ticket.guildID == guildID
+	})
+
+	if !waiting && l.available(guildID) {
+		l.start(guildID)
+		return nil, true
+	}
+
+	if !queue {
+		return nil, false
+	}
+
+	ticket := &concurrencyTicket{
+		guildID: guildID,
+		ready:   make(chan struct{}),
+		moved:   make(chan struct{}, 1),
+	}
+
+	l.waiting = append(l.waiting, ticket)
+	return ticket, false
+}
+
+// Frees a slot and starts the waiting tickets that fit.
+func (l *concurrencyLimiter) Release(guildID string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.running--
+	l.guilds[guildID]--
+	if l.guilds[guildID] <= 0 {
+		delete(l.guilds, guildID)
+	}
+
+	remaining := l.waiting[:0]
+	for _, ticket := range l.waiting {
+		if l.available(ticket.guildID) {
+			l.start(ticket.guildID)
+			close(ticket.ready)
+			continue
+		}
+
+		remaining = append(remaining, ticket)
+	}
+
+	l.waiting = remaining
+	l.notify()
+}
+
+// Removes a ticket from the queue, reports false if it had already started.
+func (l *concurrencyLimiter) Cancel(ticket *concurrencyTicket) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	index := slices.Index(l.waiting, ticket)
+	if index < 0 {
+		return false
+	}
+
+	l.waiting = slices.Delete(l.waiting, index, index+1)
+	l.notify()
+
+	return true
+}
+
+// Returns the 1-based position of the ticket in the queue, 0 when it is no
+// longer waiting.
+func (l *concurrencyLimiter) Position(ticket *concurrencyTicket) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return slices.Index(l.waiting, ticket) + 1
+}
+
+func (l *concurrencyLimiter) available(guildID string) bool {
+	if l.running >= l.limit {
+		return false
+	}
+
+	return l.perGuild <= 0 || l.guilds[guildID] < l.perGuild
+}
+
+func (l *concurrencyLimiter) start(guildID string) {
+	l.running++
+	l.guilds[guildID]++
+}
+
+func (l *concurrencyLimiter) notify() {
+	for _, ticket := range l.waiting {
+		select {
+		case ticket.moved <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package middlewares
+
+import "testing"
+
+func newLimiter(limit, perGuild int) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		limit:    limit,
+		perGuild: perGuild,
+		guilds:   make(map[string]int),
+	}
+}
+
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		perGuild int
+		queue    bool
+		guilds   []string
+		started  []bool
+		queued   []bool
+	}{
+		{
+			name:    "global limit",
+			limit:   2,
+			queue:   true,
+			guilds:  []string{"a", "b", "c"},
+			started: []bool{true, true, false},
+			queued:  []bool{false, false, true},
+		},
+		{
+			name:     "per guild limit",
+			limit:    4,
+			perGuild: 1,
+			queue:    true,
+			guilds:   []string{"a", "a", "b"},
+			started:  []bool{true, false, true},
+			queued:   []bool{false, true, false},
+		},
+		{
+			name:    "rejected without queue",
+			limit:   1,
+			guilds:  []string{"a", "b"},
+			started: []bool{true, false},
+			queued:  []bool{false, false},
+		},
+		{
+			name:     "guilds never skip their waiting tickets",
+			limit:    2,
+			perGuild: 1,
+			queue:    true,
+			guilds:   []string{"a", "a", "b", "a"},
+			started:  []bool{true, false, true, false},
+			queued:   []bool{false, true, false, true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := newLimiter(test.limit, test.perGuild)
+
+			for i, guildID := range test.guilds {
+				ticket, started := limiter.Acquire(guildID, test.queue)
+				if started != test.started[i] || (ticket != nil) != test.queued[i] {
+					t.Errorf("Acquire(%q) #%d = (queued %v, started %v), want (queued %v, started %v)", guildID, i, ticket != nil, started, test.queued[i], test.started[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiterRelease(t *testing.T) {
+	limiter := newLimiter(1, 0)
+
+	if _, started := limiter.Acquire("a", true); !started {
+		t.Fatal("first execution did not start")
+	}
+
+	first, _ := limiter.Acquire("b", true)
+	second, _ := limiter.Acquire("c", true)
+
+	if position := limiter.Position(second); position != 2 {
+		t.Errorf("Position() = %d, want 2", position)
+	}
+
+	limiter.Release("a")
+
+	select {
+	case <-first.ready:
+	default:
+		t.Fatal("first ticket was not readied")
+	}
+
+	if position := limiter.Position(second); position != 1 {
+		t.Errorf("Position() = %d after release, want 1", position)
+	}
+
+	if !limiter.Cancel(second) {
+		t.Error("Cancel() = false for a waiting ticket")
+	}
+
+	if limiter.Cancel(first) {
+		t.Error("Cancel() = true for a started ticket")
+	}
+
+	limiter.Release("b")
+	if limiter.running != 0 || len(limiter.guilds) != 0 || len(limiter.waiting) != 0 {
+		t.Errorf("limiter not empty: running %d, guilds %v, waiting %d", limiter.running, limiter.guilds, len(limiter.waiting))
+	}
+}
@@ -0,0 +1,150 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.CommandMiddleware = (*CooldownMiddleware)(nil)
+
+type CooldownScope int
+
+const (
+	CooldownUser CooldownScope = iota
+	CooldownChannel
+	CooldownGuild
+	CooldownGlobal
+)
+
+// Amount of buckets kept before full ones are swept
+const cooldownSweepThreshold = 1024
+
+type cooldownBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// CooldownMiddleware rate limits commands with token buckets. Each bucket
+// holds up to burst uses and refills completely over the given period.
+type CooldownMiddleware struct {
+	logger zerolog.Logger
+	owners []string
+	scope  CooldownScope
+	burst  int
+	period time.Duration
+
+	lock    sync.Mutex
+	buckets map[string]*cooldownBucket
+}
+
+func NewCooldownMiddleware(parent zerolog.Logger, owners []string, scope CooldownScope, burst int, period time.Duration) *CooldownMiddleware {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &CooldownMiddleware{
+		logger:  parent.With().Str("middleware", "cooldown").Logger(),
+		owners:  owners,
+		scope:   scope,
+		burst:   burst,
+		period:  period,
+		buckets: make(map[string]*cooldownBucket),
+	}
+}
+
+func (m *CooldownMiddleware) Handle(command api.Command, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	name := command.Data().Name
+
+	return func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+		user := api.InteractionUser(i)
+		if slices.Contains(m.owners, user.ID) {
+			return next(c, s, i)
+		}
+
+		allowed, retryAt := m.Take(name+"/"+m.Key(i), time.Now())
+		if allowed {
+			return next(c, s, i)
+		}
+
+		m.logger.Debug().Msgf("User %s is on cooldown for command \"%s\"", user.ID, name)
+
+		return api.GetResponder(c, s, i).Reply(&discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "Slow down! >.<",
+					Color:       api.ColorWarning,
+					Description: fmt.Sprintf("This command is on cooldown, try again <t:%d:R>.", retryAt.Unix()),
+				},
+			},
+		})
+	}
+}
+
+// Returns the bucket key of the interaction for the configured scope.
+func (m *CooldownMiddleware) Key(i *discordgo.InteractionCreate) string {
+	switch m.scope {
+	case CooldownChannel:
+		return i.ChannelID
+	case CooldownGuild:
+		// Direct messages are limited per user
+		if i.GuildID == "" {
+			return api.InteractionUser(i).ID
+		}
+
+		return i.GuildID
+	case CooldownGlobal:
+		return "global"
+	default:
+		return api.InteractionUser(i).ID
+	}
+}
+
+// Takes a token from the bucket, when none is left returns when the next one
+// becomes available.
+func (m *CooldownMiddleware) Take(key string, now time.Time) (bool, time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	refill := float64(m.burst) / m.period.Seconds()
+
+	bucket, exists := m.buckets[key]
+	if !exists {
+		m.sweep(now, refill)
+
+		bucket = &cooldownBucket{tokens: float64(m.burst), updated: now}
+		m.buckets[key] = bucket
+	}
+
+	// Refill the tokens gained since the last use
+	bucket.tokens = min(float64(m.burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*refill)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / refill * float64(time.Second))
+		return false, now.Add(wait)
+	}
+
+	bucket.tokens--
+	return true, now
+}
+
+// Removes the buckets that would be full by now, they behave like new ones.
+func (m *CooldownMiddleware) sweep(now time.Time, refill float64) {
+	if len(m.buckets) < cooldownSweepThreshold {
+		return
+	}
+
+	for key, bucket := range m.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*refill >= float64(m.burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
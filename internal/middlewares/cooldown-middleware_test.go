@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+func TestCooldownTake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type take struct {
+		key     string
+		after   time.Duration
+		allowed bool
+		retry   time.Duration
+	}
+
+	tests := []struct {
+		name   string
+		burst  int
+		period time.Duration
+		takes  []take
+	}{
+		{
+			name:   "burst is used up then refills",
+			burst:  2,
+			period: time.Minute,
+			takes: []take{
+				{key: "a", after: 0, allowed: true},
+				{key: "a", after: 0, allowed: true},
+				{key: "a", after: 0, allowed: false, retry: 30 * time.Second},
+				{key: "a", after: 30 * time.Second, allowed: true, retry: 30 * time.Second},
+				{key: "a", after: 30 * time.Second, allowed: false, retry: time.Minute},
+			},
+		},
+		{
+			name:   "keys have their own buckets",
+			burst:  1,
+			period: time.Minute,
+			takes: []take{
+				{key: "a", after: 0, allowed: true},
+				{key: "b", after: 0, allowed: true},
+				{key: "a", after: 0, allowed: false, retry: time.Minute},
+			},
+		},
+		{
+			name:   "refills never exceed the burst",
+			burst:  1,
+			period: time.Minute,
+			takes: []take{
+				{key: "a", after: 0, allowed: true},
+				{key: "a", after: time.Hour, allowed: true, retry: time.Hour},
+				{key: "a", after: time.Hour, allowed: false, retry: time.Hour + time.Minute},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewCooldownMiddleware(zerolog.Nop(), nil, CooldownUser, test.burst, test.period)
+
+			for i, take := range test.takes {
+				allowed, retry := m.Take(take.key, start.Add(take.after))
+				if allowed != take.allowed {
+					t.Fatalf("Take() #%d allowed = %v, want %v", i, allowed, take.allowed)
+				}
+
+				if want := start.Add(take.retry); !allowed && !retry.Equal(want) {
+					t.Errorf("Take() #%d retry at %s, want %s", i, retry, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCooldownKey(t *testing.T) {
+	user := &discordgo.User{ID: "user"}
+
+	guild := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: user},
+	}}
+	dm := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ChannelID: "dm",
+		User:      user,
+	}}
+
+	tests := []struct {
+		scope CooldownScope
+		i     *discordgo.InteractionCreate
+		want  string
+	}{
+		{scope: CooldownUser, i: guild, want: "user"},
+		{scope: CooldownChannel, i: guild, want: "channel"},
+		{scope: CooldownGuild, i: guild, want: "guild"},
+		{scope: CooldownGuild, i: dm, want: "user"},
+		{scope: CooldownGlobal, i: dm, want: "global"},
+	}
+
+	for _, test := range tests {
+		m := NewCooldownMiddleware(zerolog.Nop(), nil, test.scope, 1, time.Minute)
+		if key := m.Key(test.i); key != test.want {
+			t.Errorf("Key() with scope %d = %q, want %q", test.scope, key, test.want)
+		}
+	}
+}
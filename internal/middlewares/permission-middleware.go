@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"context"
+	"slices"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.CommandMiddleware = (*PermissionMiddleware)(nil)
+var _ api.ComponentMiddleware = (*PermissionMiddleware)(nil)
+
+// PermissionMiddleware stops the execution when the user does not meet the
+// requirement. Bot owners always meet it.
+type PermissionMiddleware struct {
+	logger      zerolog.Logger
+	owners      []string
+	requirement string
+	allowed     func(i *discordgo.InteractionCreate) bool
+}
+
+// Only allows the bot owners.
+func RequireOwner(parent zerolog.Logger, owners []string) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		logger:      parent.With().Str("middleware", "require-owner").Logger(),
+		owners:      owners,
+		requirement: "Only the owners of the bot can use this.",
+		allowed: func(i *discordgo.InteractionCreate) bool {
+			return false
+		},
+	}
+}
+
+// Only allows guild members with all the given permissions.
+func RequirePermissions(parent zerolog.Logger, owners []string, permissions int64) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		logger:      parent.With().Str("middleware", "require-permissions").Logger(),
+		owners:      owners,
+		requirement: "You do not have the permissions required to use this.",
+		allowed: func(i *discordgo.InteractionCreate) bool {
+			if i.Member == nil {
+				return false
+			}
+
+			if i.Member.Permissions&discordgo.PermissionAdministrator != 0 {
+				return true
+			}
+
+			return i.Member.Permissions&permissions == permissions
+		},
+	}
+}
+
+// Only allows guild members with any of the given roles.
+func RequireRole(parent zerolog.Logger, owners []string, roleIDs ...string) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		logger:      parent.With().Str("middleware", "require-role").Logger(),
+		owners:      owners,
+		requirement: "You do not have the role required to use this.",
+		allowed: func(i *discordgo.InteractionCreate) bool {
+			if i.Member == nil {
+				return false
+			}
+
+			return slices.ContainsFunc(i.Member.Roles, func(role string) bool {
+				return slices.Contains(roleIDs, role)
+			})
+		},
+	}
+}
+
+func (p *PermissionMiddleware) Handle(command api.Command, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	return p.Wrap(command.Data().Name, next)
+}
+
+func (p *PermissionMiddleware) HandleComponent(component api.Component, next api.ComponentExecuteFunc) api.ComponentExecuteFunc {
+	return api.ComponentExecuteFunc(p.Wrap(component.Data().Prefix, api.CommandExecuteFunc(next)))
+}
+
+func (p *PermissionMiddleware) Wrap(name string, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	return func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+		user := api.InteractionUser(i)
+		if slices.Contains(p.owners, user.ID) || p.allowed(i) {
+			return next(c, s, i)
+		}
+
+		p.logger.Warn().Msgf("User %s is not allowed to use interaction \"%s\"", user.ID, name)
+
+		// Rendered by the recover middleware like any other permission error
+		return api.NewPermissionError(p.requirement)
+	}
+}
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+)
+
+var _ api.Command = (*ErrorCommand)(nil)
+var _ api.EphemeralCommand = (*ErrorCommand)(nil)
+
+var ErrorCommandPermissions int64 = discordgo.PermissionAdministrator
+
+type ErrorLookupOptions struct {
+	ID string `option:"id" description:"ID of the error to look up" required:"true"`
+}
+
+type ErrorRecentOptions struct {
+	Limit int `option:"limit" description:"Number of errors to list" default:"10" min:"1" max:"25"`
+}
+
+type ErrorCommand struct {
+	logger  zerolog.Logger
+	reports api.ErrorReportStore
+	router  api.SubcommandRouter
+}
+
+func NewErrorCommand(parent zerolog.Logger, reports api.ErrorReportStore) *ErrorCommand {
+	e := &ErrorCommand{
+		logger:  parent.With().Str("command", "error").Logger(),
+		reports: reports,
+	}
+
+	e.router = api.SubcommandRouter{
+		Subcommands: []api.Subcommand{
+			{
+				Name:        "lookup",
+				Description: "Shows the report of an error.",
+				Options:     api.MustGenerateOptions(ErrorLookupOptions{}),
+				Execute:     e.HandleLookup,
+			},
+			{
+				Name:        "recent",
+				Description: "Lists the latest reported errors.",
+				Options:     api.MustGenerateOptions(ErrorRecentOptions{}),
+				Execute:     e.HandleRecent,
+			},
+		},
+	}
+
+	return e
+}
+
+func (e *ErrorCommand) Data() discordgo.ApplicationCommand {
+	return discordgo.ApplicationCommand{
+		Name:                     "error",
+		Description:              "Inspect reported errors",
+		DefaultMemberPermissions: &ErrorCommandPermissions,
+		Options:                  e.router.Options(),
+	}
+}
+
+// Error reports are only shown to whoever asked for them
+func (e *ErrorCommand) Ephemeral() bool {
+	return true
+}
+
+func (e *ErrorCommand) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return e.router.Execute(e, c, s, i)
+}
+
+func (e *ErrorCommand) HandleLookup(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options ErrorLookupOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	id, err := xid.FromString(strings.Trim(options.ID, "` "))
+	if err != nil {
+		return api.NewUserError(fmt.Sprintf("`%s` is not a valid error ID.", options.ID))
+	}
+
+	report, err := e.reports.Get(id)
+	if errors.Is(err, api.ErrReportNotFound) {
+		return api.NewNotFoundError(fmt.Sprintf("No error was reported with ID `%s`.", id))
+	} else if err != nil {
+		return api.NewInternalError("Failed to read the error report.", err)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color:       api.ColorResult,
+		Title:       "Error report",
+		Description: api.CodeBlock(report.Message, 2000),
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Error ID",
+				Value:  fmt.Sprintf("`%s`", report.ID),
+				Inline: true,
+			},
+			{
+				Name:   "Server Time",
+				Value:  fmt.Sprintf("<t:%d:f>", report.Time.Unix()),
+				Inline: true,
+			},
+			{
+				Name:   "Source",
+				Value:  reportSource(report),
+				Inline: true,
+			},
+		},
+	}
+
+	if report.UserID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "User",
+			Value:  fmt.Sprintf("<@%s>", report.UserID),
+			Inline: true,
+		})
+	}
+
+	if report.GuildID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Guild",
+			Value:  fmt.Sprintf("`%s`", report.GuildID),
+			Inline: true,
+		})
+	}
+
+	if report.Options != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Options",
+			Value: api.CodeBlock(report.Options, 1000),
+		})
+	}
+
+	// Stacks are way too long for embeds, they are sent as a file
+	var files []*discordgo.File
+	if report.Stack != "" {
+		files = append(files, &discordgo.File{
+			Name:        fmt.Sprintf("st-%s.txt", report.ID),
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(report.Stack),
+		})
+	}
+
+	return api.GetResponder(c, s, i).Respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:  discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Files:  files,
+		},
+	})
+}
+
+func (e *ErrorCommand) HandleRecent(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options ErrorRecentOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	reports, err := e.reports.Recent(options.Limit)
+	if err != nil {
+		return api.NewInternalError("Failed to read the error reports.", err)
+	}
+
+	var description strings.Builder
+	for _, report := range reports {
+		fmt.Fprintf(&description, "`%s` <t:%d:R> %s\n", report.ID, report.Time.Unix(), reportSource(report))
+	}
+
+	if len(reports) == 0 {
+		description.WriteString("No errors have been reported yet! :3")
+	}
+
+	return api.GetResponder(c, s, i).Respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Color:       api.ColorResult,
+					Title:       "Recent errors",
+					Description: description.String(),
+				},
+			},
+		},
+	})
+}
+
+func reportSource(report *api.ErrorReport) string {
+	source := fmt.Sprintf("%s `%s`", report.Kind, report.Name)
+	if report.Kind == "" {
+		source = fmt.Sprintf("`%s`", report.Name)
+	}
+
+	if report.Panic {
+		source += " (panic)"
+	}
+
+	return source
+}
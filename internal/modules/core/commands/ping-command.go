@@ -46,7 +46,7 @@ func (p *PingCommand) Execute(c context.Context, s *discordgo.Session, i *discor
 		},
 	}
 
-	if err := s.InteractionRespond(i.Interaction, response); err != nil {
+	if err := api.GetResponder(c, s, i).Respond(response); err != nil {
 		return err
 	}
 
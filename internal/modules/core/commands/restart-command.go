@@ -2,9 +2,6 @@ package commands
 
 import (
 	"context"
-	"errors"
-	"os"
-	"slices"
 
 	"github.com/DownloadableFox/twotto-v2/internal/api"
 	"github.com/bwmarrin/discordgo"
@@ -14,12 +11,16 @@ import (
 var _ api.Command = (*RestartCommand)(nil)
 
 type RestartCommand struct {
-	logger zerolog.Logger
+	logger   zerolog.Logger
+	shutdown context.CancelFunc
 }
 
-func NewRestartCommand(parent zerolog.Logger) *RestartCommand {
+// The shutdown function stops the bot the same way a termination signal does,
+// the process manager is expected to start it again.
+func NewRestartCommand(parent zerolog.Logger, shutdown context.CancelFunc) *RestartCommand {
 	return &RestartCommand{
-		logger: parent.With().Str("command", "restart").Logger(),
+		logger:   parent.With().Str("command", "restart").Logger(),
+		shutdown: shutdown,
 	}
 }
 
@@ -33,21 +34,6 @@ func (r *RestartCommand) Data() discordgo.ApplicationCommand {
 
 // Execute implements api.Command.
 func (r *RestartCommand) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	var owners = []string{"556132236697665547", "836684190987583576", "610825796285890581"}
-
-	// Get the user
-	var userId string
-	if i.Member != nil {
-		userId = i.Member.User.ID
-	} else {
-		userId = i.User.ID
-	}
-
-	// Check if the user is an owner
-	if !slices.Contains(owners, userId) {
-		return errors.New("you are not authorized to use this command")
-	}
-
 	// Send a response
 	response := &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -63,15 +49,15 @@ func (r *RestartCommand) Execute(c context.Context, s *discordgo.Session, i *dis
 		},
 	}
 
-	if err := s.InteractionRespond(i.Interaction, response); err != nil {
+	if err := api.GetResponder(c, s, i).Respond(response); err != nil {
 		return err
 	}
 
 	// Log the restart
 	r.logger.Warn().Msg("Restart command received- Restarting bot...")
 
-	// Close the session
-	os.Exit(0)
+	// Shut down gracefully, closing the session and the database
+	r.shutdown()
 
 	return nil
 }
@@ -0,0 +1,321 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.AutocompleteCommand = (*TasksCommand)(nil)
+var _ api.EphemeralCommand = (*TasksCommand)(nil)
+
+var TasksCommandPermissions int64 = discordgo.PermissionAdministrator
+
+// Keeps the list well below the embed limits of 25 fields and 6000 characters
+const TasksPerPage = 10
+
+// How long /tasks run waits for the result before moving it to the channel
+const TaskRunWait = 10 * time.Second
+
+type TaskListOptions struct {
+	Page int `option:"page" description:"Page of the list to show" default:"1" min:"1"`
+}
+
+type TaskOptions struct {
+	Name string `option:"name" description:"Name of the task" required:"true" autocomplete:"true"`
+}
+
+type TasksCommand struct {
+	logger zerolog.Logger
+	tasks  api.TaskController
+	router api.SubcommandRouter
+}
+
+func NewTasksCommand(parent zerolog.Logger, tasks api.TaskController) *TasksCommand {
+	t := &TasksCommand{
+		logger: parent.With().Str("command", "tasks").Logger(),
+		tasks:  tasks,
+	}
+
+	t.router = api.SubcommandRouter{
+		Subcommands: []api.Subcommand{
+			{
+				Name:        "list",
+				Description: "Lists the scheduled tasks.",
+				Options:     api.MustGenerateOptions(TaskListOptions{}),
+				Execute:     t.HandleList,
+			},
+			{
+				Name:        "run",
+				Description: "Runs a task right now.",
+				Options:     api.MustGenerateOptions(TaskOptions{}),
+				Execute:     t.HandleRun,
+			},
+			{
+				Name:        "pause",
+				Description: "Skips the scheduled runs of a task.",
+				Options:     api.MustGenerateOptions(TaskOptions{}),
+				Execute:     t.HandlePause,
+			},
+			{
+				Name:        "resume",
+				Description: "Resumes the scheduled runs of a task.",
+				Options:     api.MustGenerateOptions(TaskOptions{}),
+				Execute:     t.HandleResume,
+			},
+		},
+	}
+
+	return t
+}
+
+func (t *TasksCommand) Data() discordgo.ApplicationCommand {
+	return discordgo.ApplicationCommand{
+		Name:                     "tasks",
+		Description:              "Inspect and control scheduled tasks",
+		DefaultMemberPermissions: &TasksCommandPermissions,
+		Options:                  t.router.Options(),
+	}
+}
+
+// Task details are only shown to whoever asked for them
+func (t *TasksCommand) Ephemeral() bool {
+	return true
+}
+
+func (t *TasksCommand) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return t.router.Execute(t, c, s, i)
+}
+
+func (t *TasksCommand) Autocomplete(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	focused := api.FocusedOption(i.ApplicationCommandData().Options)
+	if focused == nil || focused.Name != "name" {
+		return []*discordgo.ApplicationCommandOptionChoice{}, nil
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, info := range t.tasks.ListTasks() {
+		if strings.Contains(info.Data.Name, strings.ToLower(focused.StringValue())) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  info.Data.Name,
+				Value: info.Data.Name,
+			})
+		}
+	}
+
+	return choices, nil
+}
+
+func (t *TasksCommand) HandleList(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options TaskListOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Color: api.ColorResult,
+		Title: "Scheduled tasks",
+	}
+
+	infos := t.tasks.ListTasks()
+	pages := max(1, (len(infos)+TasksPerPage-1)/TasksPerPage)
+	if options.Page > pages {
+		return api.NewUserError(fmt.Sprintf("There are only %d pages of tasks.", pages))
+	}
+
+	start := (options.Page - 1) * TasksPerPage
+	for _, info := range infos[start:min(start+TasksPerPage, len(infos))] {
+		// One-shot jobs have no cron spec
+		schedule := "once"
+		if info.Data.Cron != "" {
+			schedule = fmt.Sprintf("`%s`", info.Data.Cron)
+		}
+
+		if info.Data.Timezone != "" {
+			schedule += fmt.Sprintf(" (%s)", info.Data.Timezone)
+		}
+
+		next := "never"
+		if !info.Next.IsZero() {
+			next = fmt.Sprintf("<t:%d:R>", info.Next.Unix())
+		}
+
+		lines := []string{
+			fmt.Sprintf("Schedule: %s", schedule),
+			fmt.Sprintf("Next run: %s", next),
+			fmt.Sprintf("Last run: %s", describeRun(info.LastRun)),
+		}
+
+		name := info.Data.Name
+		if info.Job {
+			name += " (job)"
+		}
+
+		if info.Paused {
+			name += " (paused)"
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  api.Truncate(name, 256),
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+
+	if len(embed.Fields) == 0 {
+		embed.Description = "There are no tasks scheduled! :3"
+	}
+
+	if pages > 1 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d of %d (%d tasks)", options.Page, pages, len(infos)),
+		}
+	}
+
+	return t.respond(c, s, i, embed)
+}
+
+func (t *TasksCommand) HandleRun(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options TaskOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	// Tasks might take a while, the reply is edited once done
+	if err := api.GetResponder(c, s, i).Defer(true); err != nil {
+		return err
+	}
+
+	type result struct {
+		run api.TaskRun
+		err error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		run, err := t.tasks.RunTask(options.Name)
+		results <- result{run, err}
+	}()
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return t.taskError(options.Name, result.err)
+		}
+		return t.respond(c, s, i, runEmbed(options.Name, &result.run))
+	case <-time.After(TaskRunWait):
+	}
+
+	// Tasks can outlive the interaction token (and the command timeout), the
+	// result of the slow ones is posted to the channel instead
+	go func() {
+		result := <-results
+		if result.err != nil {
+			t.logger.Warn().Err(result.err).Msgf("Failed to run task %q", options.Name)
+			return
+		}
+
+		if _, err := s.ChannelMessageSendEmbed(i.ChannelID, runEmbed(options.Name, &result.run)); err != nil {
+			t.logger.Warn().Err(err).Msgf("Failed to post the result of task %q", options.Name)
+		}
+	}()
+
+	return t.respond(c, s, i, &discordgo.MessageEmbed{
+		Color:       api.ColorInfo,
+		Title:       "Task started!",
+		Description: fmt.Sprintf("`%s` is still running, the result will be posted in this channel.", options.Name),
+	})
+}
+
+func runEmbed(name string, run *api.TaskRun) *discordgo.MessageEmbed {
+	color := api.ColorError
+	switch run.Outcome {
+	case api.OutcomeSuccess:
+		color = api.ColorSuccess
+	case api.OutcomeSkipped:
+		color = api.ColorWarning
+	}
+
+	return &discordgo.MessageEmbed{
+		Color:       color,
+		Title:       fmt.Sprintf("Ran task %s", name),
+		Description: describeRun(run),
+	}
+}
+
+func (t *TasksCommand) HandlePause(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options TaskOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	if err := t.tasks.PauseTask(options.Name); err != nil {
+		return t.taskError(options.Name, err)
+	}
+
+	return t.respond(c, s, i, &discordgo.MessageEmbed{
+		Color:       api.ColorSuccess,
+		Title:       "Task paused!",
+		Description: fmt.Sprintf("Scheduled runs of `%s` will be skipped until it is resumed.", options.Name),
+	})
+}
+
+func (t *TasksCommand) HandleResume(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var options TaskOptions
+	if err := api.BindOptions(i, &options); err != nil {
+		return err
+	}
+
+	if err := t.tasks.ResumeTask(options.Name); err != nil {
+		return t.taskError(options.Name, err)
+	}
+
+	return t.respond(c, s, i, &discordgo.MessageEmbed{
+		Color:       api.ColorSuccess,
+		Title:       "Task resumed!",
+		Description: fmt.Sprintf("`%s` will run on its schedule again.", options.Name),
+	})
+}
+
+func (t *TasksCommand) taskError(name string, err error) error {
+	if errors.Is(err, api.ErrUnknownTask) {
+		return api.NewNotFoundError(fmt.Sprintf("There is no task named `%s`.", name))
+	}
+
+	if errors.Is(err, api.ErrTasksNotPublished) {
+		return api.NewUnavailableError("Tasks are not running yet, try again in a moment.", err)
+	}
+
+	return err
+}
+
+func (t *TasksCommand) respond(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) error {
+	return api.GetResponder(c, s, i).Respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:  discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+}
+
+func describeRun(run *api.TaskRun) string {
+	if run == nil {
+		return "never"
+	}
+
+	description := fmt.Sprintf("%s <t:%d:R> in %s", run.Outcome, run.Started.Unix(), run.Finished.Sub(run.Started).Round(time.Millisecond))
+	if run.ErrorID != "" {
+		description += fmt.Sprintf(" (`%s`)", run.ErrorID)
+	} else if run.Error != "" {
+		// Errors without a report can be arbitrarily long
+		description += fmt.Sprintf(": %s", api.Truncate(run.Error, 200))
+	}
+
+	return description
+}
@@ -1,22 +1,36 @@
 package core
 
 import (
+	"context"
+
 	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/DownloadableFox/twotto-v2/internal/config"
+	"github.com/DownloadableFox/twotto-v2/internal/middlewares"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/core/commands"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/core/events"
-	"github.com/DownloadableFox/twotto-v2/internal/modules/core/middlewares"
 	"github.com/rs/zerolog"
 )
 
 var _ api.Module = (*CoreModule)(nil)
 
 type CoreModule struct {
-	Logger zerolog.Logger
+	Logger         zerolog.Logger
+	Config         config.Config
+	Reports        api.ErrorReportStore
+	TaskController api.TaskController
+	// Stops the bot, used to restart it
+	Shutdown context.CancelFunc
 }
 
-func NewCoreModule(parent zerolog.Logger) *CoreModule {
+func NewCoreModule(parent zerolog.Logger, config config.Config, reports api.ErrorReportStore, tasks api.TaskController, shutdown context.CancelFunc) *CoreModule {
+	logger := parent.With().Str("module", "core").Logger()
+
 	return &CoreModule{
-		Logger: parent.With().Str("module", "core").Logger(),
+		Logger:         logger,
+		Config:         config,
+		Reports:        reports,
+		TaskController: tasks,
+		Shutdown:       shutdown,
 	}
 }
 
@@ -29,26 +43,39 @@ func (m *CoreModule) Events() ([]api.EventStack, error) {
 }
 
 func (m *CoreModule) Commands() ([]api.CommandStack, error) {
-	middlewares := []api.CommandMiddleware{
-		middlewares.NewRecoverMiddleware(m.Logger),
-	}
+	owners := m.Config.Owners
 
 	return []api.CommandStack{
 		api.CompileCommand(
 			commands.NewPingCommand(m.Logger),
-			middlewares...,
 		),
 		api.CompileCommand(
-			commands.NewRestartCommand(m.Logger),
-			middlewares...,
+			commands.NewRestartCommand(m.Logger, m.Shutdown),
+			middlewares.RequireOwner(m.Logger, owners),
 		),
 		api.CompileCommand(
 			commands.NewErrorTestCommand(m.Logger),
-			middlewares...,
+			middlewares.RequireRole(m.Logger, owners, m.Config.AdminRoles...),
+		).WithScope(api.ScopeDev),
+		api.CompileCommand(
+			commands.NewErrorCommand(m.Logger, m.Reports),
+			middlewares.RequireOwner(m.Logger, owners),
+		),
+		api.CompileCommand(
+			commands.NewTasksCommand(m.Logger, m.TaskController),
+			middlewares.RequireOwner(m.Logger, owners),
 		),
 	}, nil
 }
 
+func (m *CoreModule) Components() ([]api.ComponentStack, error) {
+	return []api.ComponentStack{}, nil
+}
+
+func (m *CoreModule) JobHandlers() (map[string]api.JobHandler, error) {
+	return map[string]api.JobHandler{}, nil
+}
+
 func (m *CoreModule) Tasks() ([]api.TaskStack, error) {
 	return []api.TaskStack{}, nil
 }
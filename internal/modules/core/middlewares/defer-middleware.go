@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.CommandMiddleware = (*DeferMiddleware)(nil)
+
+// Discord invalidates interactions that are not answered within 3 seconds
+const DefaultDeferThreshold = 2 * time.Second
+
+type DeferMiddleware struct {
+	logger    zerolog.Logger
+	threshold time.Duration
+}
+
+func NewDeferMiddleware(parent zerolog.Logger, threshold time.Duration) *DeferMiddleware {
+	if threshold <= 0 {
+		threshold = DefaultDeferThreshold
+	}
+
+	return &DeferMiddleware{
+		logger:    parent.With().Str("middleware", "defer").Logger(),
+		threshold: threshold,
+	}
+}
+
+func (d *DeferMiddleware) Handle(command api.Command, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	name := command.Data().Name
+
+	// Commands not declaring a preference are deferred publicly
+	ephemeral := false
+	if preference, ok := command.(api.EphemeralCommand); ok {
+		ephemeral = preference.Ephemeral()
+	}
+
+	return func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+		responder := api.GetResponder(c, s, i)
+
+		// Defer on behalf of the command if it takes too long to respond
+		timer := time.AfterFunc(d.threshold, func() {
+			if responder.State() != api.ResponseNone {
+				return
+			}
+
+			d.logger.Debug().Msgf("Command %q did not respond in %s, deferring", name, d.threshold)
+
+			if err := responder.Defer(ephemeral); err != nil {
+				d.logger.Warn().Err(err).Msgf("Failed to defer command %q", name)
+			}
+		})
+		defer timer.Stop()
+
+		return next(api.WithResponder(c, responder), s, i)
+	}
+}
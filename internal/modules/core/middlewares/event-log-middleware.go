@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.AnyEventMiddleware = (*EventLogMiddleware)(nil)
+
+type EventLogMiddleware struct {
+	logger zerolog.Logger
+}
+
+func NewEventLogMiddleware(parent zerolog.Logger) *EventLogMiddleware {
+	return &EventLogMiddleware{
+		logger: parent.With().Str("middleware", "event-log").Logger(),
+	}
+}
+
+func (l *EventLogMiddleware) HandleAny(data api.EventData, next api.AnyEventExecuteFunc) api.AnyEventExecuteFunc {
+	logger := l.logger.With().Str("event", data.Name).Logger()
+
+	return func(c context.Context, s *discordgo.Session, e any) error {
+		return runLogged(logger, func() error {
+			return next(c, s, e)
+		})
+	}
+}
+
+// Runs the handler of an event or task logging its outcome and duration.
+// Panics and errors are recovered and reported by the managers, they are
+// only passed through here.
+func runLogged(logger zerolog.Logger, fn func() error) error {
+	start := time.Now()
+
+	// Panics are noticed without recovering them
+	finished := false
+	defer func() {
+		if !finished {
+			logger.Error().Str("outcome", string(api.OutcomePanic)).Dur("duration", time.Since(start)).Msg("Execution panicked")
+		}
+	}()
+
+	err := fn()
+	finished = true
+
+	if err != nil {
+		logger.Warn().Err(err).Str("outcome", string(api.OutcomeError)).Dur("duration", time.Since(start)).Msg("Execution failed")
+		return err
+	}
+
+	logger.Debug().Str("outcome", string(api.OutcomeSuccess)).Dur("duration", time.Since(start)).Msg("Executed successfully")
+	return nil
+}
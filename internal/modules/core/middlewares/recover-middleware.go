@@ -1,10 +1,9 @@
 package middlewares
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"runtime"
+	"runtime/debug"
 
 	"github.com/DownloadableFox/twotto-v2/internal/api"
 	"github.com/bwmarrin/discordgo"
@@ -13,27 +12,69 @@ import (
 )
 
 var _ api.CommandMiddleware = (*RecoverMiddleware)(nil)
+var _ api.ComponentMiddleware = (*RecoverMiddleware)(nil)
+
+// How errors of each kind are shown to users
+type ErrorPresentation struct {
+	Title     string
+	Color     int
+	Ephemeral bool
+	// Whether an error ID is generated and the error is logged as a failure
+	Report bool
+}
+
+var errorPresentations = map[api.ErrorKind]ErrorPresentation{
+	api.ErrorInternal:    {Title: "Oh no! :(", Color: api.ColorError, Ephemeral: true, Report: true},
+	api.ErrorUser:        {Title: "Oops! >.<", Color: api.ColorWarning, Ephemeral: true},
+	api.ErrorPermission:  {Title: "Not allowed! >:(", Color: api.ColorWarning, Ephemeral: true},
+	api.ErrorNotFound:    {Title: "Not found! :c", Color: api.ColorWarning},
+	api.ErrorUnavailable: {Title: "Service unavailable! -w-", Color: api.ColorError, Report: true},
+}
 
 type RecoverMiddleware struct {
-	logger zerolog.Logger
+	logger    zerolog.Logger
+	contactID string
 }
 
-func NewRecoverMiddleware(parent zerolog.Logger) *RecoverMiddleware {
+func NewRecoverMiddleware(parent zerolog.Logger, contactID string) *RecoverMiddleware {
 	return &RecoverMiddleware{
-		logger: parent.With().Str("middleware", "recover").Logger(),
+		logger:    parent.With().Str("middleware", "recover").Logger(),
+		contactID: contactID,
 	}
 }
 
 func (r *RecoverMiddleware) Handle(command api.Command, next api.CommandExecuteFunc) api.CommandExecuteFunc {
+	return r.Wrap(command.Data().Name, next)
+}
+
+func (r *RecoverMiddleware) HandleComponent(component api.Component, next api.ComponentExecuteFunc) api.ComponentExecuteFunc {
+	return api.ComponentExecuteFunc(r.Wrap(component.Data().Prefix, api.CommandExecuteFunc(next)))
+}
+
+func (r *RecoverMiddleware) Wrap(name string, next api.CommandExecuteFunc) api.CommandExecuteFunc {
 	return func(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
-		defer r.PanicWrap(s, i)
+		defer r.PanicWrap(c, s, i, name)
 
 		if err := next(c, s, i); err != nil {
-			r.logger.Error().Err(err).Msgf("Caught an error while executing interaction \"%s\"!", command.Data().Name)
+			kind, message := api.ClassifyError(err)
+			presentation := errorPresentations[kind]
+
+			// Only failures get an error ID, user mistakes are not reported
+			var id *xid.ID
+			if presentation.Report {
+				generated := xid.New()
+				id = &generated
+
+				r.logger.Error().Err(err).Str("error_id", id.String()).Msgf("Caught an error while executing interaction \"%s\"!", name)
+
+				r.Report(c, api.NewInteractionReport(c, i, generated, err.Error()), name)
+			} else {
+				r.logger.Debug().Err(err).Msgf("Rejected interaction \"%s\"", name)
+			}
 
 			// Reply to the interaction with an error embed
-			errorEmbed := r.CreateErrorEmbed(err, xid.New()) // Generate embed
-			if err := r.AttemptReply(s, i, errorEmbed); err != nil {
+			errorEmbed := r.CreateErrorEmbed(presentation, message, id)
+			if err := r.AttemptReply(c, s, i, errorEmbed, presentation.Ephemeral); err != nil {
 				r.logger.Warn().Err(err).Msg("Failed to reply to interaction!")
 			}
 		}
@@ -42,70 +83,81 @@ func (r *RecoverMiddleware) Handle(command api.Command, next api.CommandExecuteF
 	}
 }
 
-func (r *RecoverMiddleware) PanicWrap(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (r *RecoverMiddleware) PanicWrap(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, name string) {
 	if rec := recover(); rec != nil {
-		// Generate the stacktrace
-		stacktrace := make([]byte, 4096)
-		count := runtime.Stack(stacktrace, false)
-		reader := bytes.NewReader(stacktrace[:count])
+		id := xid.New()
+
+		// Generate the full stacktrace, it is only kept in the report
+		stacktrace := debug.Stack()
 
 		// Print stacktrace
-		r.logger.Error().Any("panic", rec).Msg("Recovered from panic in command execution")
-		r.logger.Debug().Msg(string(stacktrace[:count]))
+		r.logger.Error().Any("panic", rec).Str("error_id", id.String()).Msg("Recovered from panic in command execution")
+		r.logger.Debug().Msg(string(stacktrace))
+
+		report := api.NewInteractionReport(c, i, id, fmt.Sprint(rec))
+		report.Panic = true
+		report.Stack = string(stacktrace)
+		r.Report(c, report, name)
 
 		// Generate embed
-		id := xid.New()
 		errorEmbed := r.CreateFatalErrorEmbed(id)
 
-		if err := r.AttemptReply(s, i, errorEmbed); err != nil {
+		if err := r.AttemptReply(c, s, i, errorEmbed, true); err != nil {
 			r.logger.Warn().Err(err).Msg("Failed to reply to interaction!")
 		}
+	}
+}
 
-		s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
-			Flags: discordgo.MessageFlagsEphemeral,
-			Files: []*discordgo.File{
-				{
-					Name:        fmt.Sprintf("st-%s.txt", id),
-					ContentType: "text/plain",
-					Reader:      reader,
-				},
-			},
-		})
+// Hands the report to the error sink of the invocation.
+func (r *RecoverMiddleware) Report(c context.Context, report *api.ErrorReport, name string) {
+	if report.Name == "" {
+		report.Name = name
 	}
+
+	api.ReportError(c, report)
 }
 
-func (r *RecoverMiddleware) CreateErrorEmbed(err error, id xid.ID) *discordgo.MessageEmbed {
-	return &discordgo.MessageEmbed{
-		Color:       api.ColorError,
-		Title:       "Oh no! :(",
-		Description: "Sorry! An unexpected error occurred while executing this event.\nIf this keeps happening contact <@556132236697665547>.",
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:  "Error Message",
-				Value: err.Error(),
-			},
-			{
-				Name:   "Error ID",
-				Value:  fmt.Sprintf("`%s`", id),
-				Inline: true,
-			},
-			{
-				Name:   "Server Time",
-				Value:  fmt.Sprintf("<t:%d:f>", id.Time().Unix()),
-				Inline: true,
-			},
+func (r *RecoverMiddleware) CreateErrorEmbed(presentation ErrorPresentation, message string, id *xid.ID) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Color:       presentation.Color,
+		Title:       presentation.Title,
+		Description: message,
+	}
+
+	// Untyped errors might leak internals, a generic message is shown instead
+	if embed.Description == "" {
+		embed.Description = "Sorry! An unexpected error occurred while executing this event."
+	}
+
+	if id == nil {
+		return embed
+	}
+
+	embed.Description += r.ContactLine()
+	embed.Fields = []*discordgo.MessageEmbedField{
+		{
+			Name:   "Error ID",
+			Value:  fmt.Sprintf("`%s`", id),
+			Inline: true,
 		},
-		Footer: &discordgo.MessageEmbedFooter{
-			Text: "The following error was reported!",
+		{
+			Name:   "Server Time",
+			Value:  fmt.Sprintf("<t:%d:f>", id.Time().Unix()),
+			Inline: true,
 		},
 	}
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: "The following error was reported!",
+	}
+
+	return embed
 }
 
 func (r *RecoverMiddleware) CreateFatalErrorEmbed(id xid.ID) *discordgo.MessageEmbed {
 	return &discordgo.MessageEmbed{
 		Color:       api.ColorError,
 		Title:       "Fatal! -w-",
-		Description: "You have encountered a fatal error! This should never happen.\nIf this keeps happening contact <@556132236697665547>.",
+		Description: "You have encountered a fatal error! This should never happen." + r.ContactLine(),
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "Error ID",
@@ -119,45 +171,28 @@ func (r *RecoverMiddleware) CreateFatalErrorEmbed(id xid.ID) *discordgo.MessageE
 			},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
-			Text: "The following error was reported!",
+			Text: "This error was reported to the developers!",
 		},
 	}
 }
 
-func (r *RecoverMiddleware) AttemptReply(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) error {
-	// Attempt to reply
-	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-			Flags:  discordgo.MessageFlagsEphemeral,
-		},
-	}); err == nil { // If reply succesful finish.
-		return nil
+func (r *RecoverMiddleware) ContactLine() string {
+	if r.contactID == "" {
+		return ""
 	}
 
-	// Check if the interaction has already been replied to
-	res, err := s.InteractionResponse(i.Interaction)
-	if err != nil {
-		return err
-	}
+	return fmt.Sprintf("\nIf this keeps happening contact <@%s>.", r.contactID)
+}
 
-	// Edit reply where possible
-	if res.Flags&discordgo.MessageFlagsLoading > 0 {
-		if _, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Embeds: &[]*discordgo.MessageEmbed{embed},
-		}); err != nil {
-			return err
-		}
-	} else {
-		// If already replied, send follow-up
-		if _, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
-			Flags:  res.Flags & discordgo.MessageFlagsEphemeral,
-			Embeds: []*discordgo.MessageEmbed{embed},
-		}); err != nil {
-			return err
-		}
+func (r *RecoverMiddleware) AttemptReply(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed, ephemeral bool) error {
+	var flags discordgo.MessageFlags
+	if ephemeral {
+		flags |= discordgo.MessageFlagsEphemeral
 	}
 
-	return nil
+	// The responder edits deferred replies and follows up answered ones
+	return api.GetResponder(c, s, i).Reply(&discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Flags:  flags,
+	})
 }
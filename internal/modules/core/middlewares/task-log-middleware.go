@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"context"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.TaskMiddleware = (*TaskLogMiddleware)(nil)
+
+type TaskLogMiddleware struct {
+	logger zerolog.Logger
+}
+
+func NewTaskLogMiddleware(parent zerolog.Logger) *TaskLogMiddleware {
+	return &TaskLogMiddleware{
+		logger: parent.With().Str("middleware", "task-log").Logger(),
+	}
+}
+
+func (l *TaskLogMiddleware) Handle(task api.Task, next api.TaskExecuteFunc) api.TaskExecuteFunc {
+	logger := l.logger.With().Str("task", task.Data().Name).Logger()
+
+	return func(c context.Context, s *discordgo.Session) error {
+		return runLogged(logger, func() error {
+			return next(c, s)
+		})
+	}
+}
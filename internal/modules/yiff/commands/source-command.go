@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/DownloadableFox/twotto-v2/internal/modules/yiff/services"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+)
+
+var _ api.MessageCommand = (*SourceCommand)(nil)
+
+// Maximum amount of matches listed in the reply
+const MaxSourceMatches = 5
+
+type SourceCommand struct {
+	service services.IE621Service
+	logger  zerolog.Logger
+}
+
+func NewSourceCommand(service services.IE621Service, parent zerolog.Logger) *SourceCommand {
+	return &SourceCommand{
+		service: service,
+		logger:  parent.With().Str("command", "source").Logger(),
+	}
+}
+
+func (sc *SourceCommand) Data() discordgo.ApplicationCommand {
+	return discordgo.ApplicationCommand{
+		Name:         "Find source on e621",
+		DMPermission: &DMPermission,
+	}
+}
+
+func (sc *SourceCommand) ExecuteMessage(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, target *discordgo.Message) error {
+	imageURL := sc.FindImage(target)
+	if imageURL == "" {
+		return api.NewUserError("This message has no image to look up.")
+	}
+
+	// Defer the response
+	responder := api.GetResponder(c, s, i)
+	if err := responder.Defer(true); err != nil {
+		return err
+	}
+
+	matches, err := sc.service.FindSimilarPosts(c, imageURL)
+	if err != nil {
+		return err
+	}
+
+	// If no posts were found
+	if len(matches) == 0 {
+		if _, err := responder.Edit(&discordgo.WebhookEdit{
+			Embeds: &[]*discordgo.MessageEmbed{{
+				Title:       "No source found!",
+				Description: "No similar posts were found on e621 for this image.",
+				Color:       api.ColorWarning,
+			}},
+		}); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if len(matches) > MaxSourceMatches {
+		matches = matches[:MaxSourceMatches]
+	}
+
+	var lines []string
+	for _, match := range matches {
+		lines = append(lines, fmt.Sprintf("[#%d](https://e621.net/posts/%d) (%.1f%% similar)", match.PostID, match.PostID, match.Score))
+	}
+
+	if _, err := responder.Edit(&discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{{
+			Title:       "Possible sources found!",
+			Description: strings.Join(lines, "\n"),
+			Color:       api.ColorResult,
+		}},
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the first image found in the message attachments or embeds
+func (sc *SourceCommand) FindImage(message *discordgo.Message) string {
+	for _, attachment := range message.Attachments {
+		if strings.HasPrefix(attachment.ContentType, "image/") {
+			return attachment.URL
+		}
+	}
+
+	for _, embed := range message.Embeds {
+		if embed.Image != nil && embed.Image.URL != "" {
+			return embed.Image.URL
+		}
+
+		if embed.Type == discordgo.EmbedTypeImage && embed.Thumbnail != nil {
+			return embed.Thumbnail.URL
+		}
+	}
+
+	return ""
+}
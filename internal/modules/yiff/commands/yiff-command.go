@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/DownloadableFox/twotto-v2/internal/api"
@@ -13,99 +14,124 @@ import (
 )
 
 var _ api.Command = (*YiffCommand)(nil)
+var _ api.AutocompleteCommand = (*YiffCommand)(nil)
 
 var DMPermission bool = true
 var NSFW bool = true
 
+type SearchOptions struct {
+	Tags  string `option:"tags" description:"Tags to search for" required:"true" autocomplete:"true"`
+	Limit int    `option:"limit" description:"Number of posts to return" default:"20" min:"1" max:"320"`
+	Page  int    `option:"page" description:"Page to return" default:"1" min:"1"`
+}
+
+type PostOptions struct {
+	ID int `option:"id" description:"ID of the post to get" required:"true" min:"1"`
+}
+
 type YiffCommand struct {
 	service services.IE621Service
 	logger  zerolog.Logger
+	router  api.SubcommandRouter
 }
 
 func NewYiffCommand(service services.IE621Service, parent zerolog.Logger) *YiffCommand {
-	return &YiffCommand{
+	y := &YiffCommand{
 		service: service,
 		logger:  parent.With().Str("command", "yiff").Logger(),
 	}
-}
 
-func (y *YiffCommand) Data() discordgo.ApplicationCommand {
-	return discordgo.ApplicationCommand{
-		Name:         "yiff",
-		Description:  "Get a random yiff from e621",
-		DMPermission: &DMPermission,
-		NSFW:         &NSFW,
-		Options: []*discordgo.ApplicationCommandOption{
+	y.router = api.SubcommandRouter{
+		Subcommands: []api.Subcommand{
 			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "random",
 				Description: "Get a random post from e621",
+				Execute:     y.HandleRandom,
 			},
 			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "search",
 				Description: "Search for posts on e621 based on tags",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionString,
-						Name:        "tags",
-						Description: "Tags to search for",
-						Required:    true,
-					},
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "limit",
-						Description: "Number of posts to return",
-						Required:    false,
-					},
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "page",
-						Description: "Page to return",
-						Required:    false,
-					},
-				},
+				Options:     api.MustGenerateOptions(SearchOptions{}),
+				Execute:     y.HandleSearch,
 			},
 			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
 				Name:        "post",
 				Description: "Get a specific post from e621",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "id",
-						Description: "ID of the post to get",
-						Required:    true,
-					},
-				},
+				Options:     api.MustGenerateOptions(PostOptions{}),
+				Execute:     y.HandlePost,
 			},
 		},
 	}
+
+	return y
 }
 
-func (y *YiffCommand) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	data := i.ApplicationCommandData()
+func (y *YiffCommand) Data() discordgo.ApplicationCommand {
+	return discordgo.ApplicationCommand{
+		Name:         "yiff",
+		Description:  "Get a random yiff from e621",
+		DMPermission: &DMPermission,
+		NSFW:         &NSFW,
+		Options:      y.router.Options(),
+	}
+}
 
+func (y *YiffCommand) Execute(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	// Defer the response
-	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-	}); err != nil {
+	if err := api.GetResponder(c, s, i).Defer(false); err != nil {
 		return err
 	}
 
 	// Handle the subcommands
-	switch data.Options[0].Name {
-	case "random":
-		return y.HandleRandom(c, s, i)
-	case "search":
-		return y.HandleSearch(c, s, i)
-	case "post":
-		return y.HandlePost(c, s, i)
-	case "popular":
-		return y.HandlePopular(c, s, i)
+	return y.router.Execute(y, c, s, i)
+}
+
+func (y *YiffCommand) Autocomplete(c context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	focused := api.FocusedOption(i.ApplicationCommandData().Options)
+	if focused == nil || focused.Name != "tags" {
+		return []*discordgo.ApplicationCommandOptionChoice{}, nil
 	}
 
-	return nil
+	// Only the last tag is completed, the previous ones are kept as typed
+	value := focused.StringValue()
+	previous, current := "", value
+	if index := strings.LastIndex(value, " "); index >= 0 {
+		previous, current = value[:index+1], value[index+1:]
+	}
+
+	// Keep the exclusion and "or" modifiers of the tag being typed
+	modifier := ""
+	if strings.HasPrefix(current, "-") || strings.HasPrefix(current, "~") {
+		modifier, current = current[:1], current[1:]
+	}
+
+	tags, err := y.service.AutocompleteTags(c, current)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(tags))
+	for _, tag := range tags {
+		suggestion := previous + modifier + tag.Name
+
+		// Discord rejects the whole response when a choice name or value is
+		// longer than 100 characters, the post count is dropped if needed
+		if len(suggestion) > 100 {
+			continue
+		}
+
+		name := fmt.Sprintf("%s (%d)", suggestion, tag.PostCount)
+		if len(name) > 100 {
+			name = suggestion
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: suggestion,
+		})
+	}
+
+	return choices, nil
 }
 
 func (y *YiffCommand) GeneratePostEmbed(post *services.E621Post) *discordgo.MessageEmbed {
@@ -131,7 +157,7 @@ func (y *YiffCommand) GeneratePostEmbed(post *services.E621Post) *discordgo.Mess
 	return embed
 }
 
-func (y *YiffCommand) PublishThread(s *discordgo.Session, channelID, messageID, tags string, posts []*services.E621Post) error {
+func (y *YiffCommand) PublishThread(ctx context.Context, s *discordgo.Session, channelID, messageID, tags string, posts []*services.E621Post) error {
 	// Assume
 	success := true
 
@@ -147,10 +173,15 @@ func (y *YiffCommand) PublishThread(s *discordgo.Session, channelID, messageID,
 
 	// Send the posts
 	for _, post := range posts {
+		// Stop uploading once the invocation is cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		s.ChannelTyping(thr.ID)
 
 		embed := y.GeneratePostEmbed(post)
-		req, err := http.NewRequest(http.MethodGet, post.URL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.URL, nil)
 		if err != nil {
 			y.logger.Warn().Err(err).Msgf("Failed to create request for post #%d (source: %s)", post.ID, post.URL)
 			success = false
@@ -195,7 +226,7 @@ func (y *YiffCommand) PublishThread(s *discordgo.Session, channelID, messageID,
 
 func (y *YiffCommand) HandleRandom(ctx context.Context, s *discordgo.Session, e *discordgo.InteractionCreate) error {
 	// Get the post
-	post, err := y.service.GetRandomPost()
+	post, err := y.service.GetRandomPost(ctx)
 	if err != nil {
 		return err
 	}
@@ -204,7 +235,7 @@ func (y *YiffCommand) HandleRandom(ctx context.Context, s *discordgo.Session, e
 	embed := y.GeneratePostEmbed(post)
 
 	// Create file request
-	req, err := http.NewRequest(http.MethodGet, post.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.URL, nil)
 	if err != nil {
 		return err
 	}
@@ -220,7 +251,7 @@ func (y *YiffCommand) HandleRandom(ctx context.Context, s *discordgo.Session, e
 		Reader: res.Body,
 	}
 
-	if _, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+	if _, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 		Files:  []*discordgo.File{file},
 	}); err != nil {
@@ -231,21 +262,16 @@ func (y *YiffCommand) HandleRandom(ctx context.Context, s *discordgo.Session, e
 }
 
 func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e *discordgo.InteractionCreate) error {
-	data := e.ApplicationCommandData().Options[0]
-
-	// Get tags
-	tags, err := api.GetStringOption(data.Options, "tags")
-	if err != nil {
+	var options SearchOptions
+	if err := api.BindOptions(e, &options); err != nil {
 		return err
 	}
 
-	// Get limit & page
-	limit := api.GetIntegerDefaultOption(data.Options, "limit", 20)
-	page := api.GetIntegerDefaultOption(data.Options, "page", 1)
+	tags, limit, page := options.Tags, options.Limit, options.Page
 
 	// Send the looking for posts embed
 	startTime := time.Now()
-	msg, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+	msg, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{{
 			Title:       "Looking for posts...",
 			Description: "Searching for posts (this may take a while) ...",
@@ -269,7 +295,7 @@ func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e
 	}
 
 	// Search for the posts
-	posts, err := y.service.SearchPosts(tags, limit, page)
+	posts, err := y.service.SearchPosts(ctx, tags, limit, page)
 	if err != nil {
 		return err
 	}
@@ -277,7 +303,7 @@ func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e
 	// If no posts were found
 	if len(posts) == 0 {
 		// Update interaction
-		if _, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+		if _, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 			Embeds: &[]*discordgo.MessageEmbed{{
 				Title:       "No posts found!",
 				Description: "No posts were found with the given tags.\n**Note:**Some files may be too large to send (25MB limit).",
@@ -303,9 +329,9 @@ func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e
 	}
 
 	// Send the posts to a thread
-	if err := y.PublishThread(s, msg.ChannelID, msg.ID, tags, posts); err != nil {
+	if err := y.PublishThread(ctx, s, msg.ChannelID, msg.ID, tags, posts); err != nil {
 		// Operation cancelled
-		s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+		api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 			Embeds: &[]*discordgo.MessageEmbed{{
 				Title:       "Operation cancelled! :(",
 				Description: "There was an issue sending the posts to a thread.",
@@ -335,7 +361,7 @@ func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e
 		durationStr += fmt.Sprintf("%ds", seconds)
 	}
 
-	if _, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+	if _, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{{
 			Title:       "Posts sent!",
 			Description: "Found posts have been sent below! **Note: **Some files might have been ommited as they were too large to send (25MB limit).",
@@ -366,22 +392,20 @@ func (y *YiffCommand) HandleSearch(ctx context.Context, s *discordgo.Session, e
 }
 
 func (y *YiffCommand) HandlePost(ctx context.Context, s *discordgo.Session, e *discordgo.InteractionCreate) error {
-	data := e.ApplicationCommandData().Options[0]
-
-	// Get the post
-	postId, err := api.GetIntegerOption(data.Options, "id")
-	if err != nil {
+	var options PostOptions
+	if err := api.BindOptions(e, &options); err != nil {
 		return err
 	}
 
-	post, err := y.service.GetPostByID(postId)
+	// Get the post
+	post, err := y.service.GetPostByID(ctx, options.ID)
 	if err != nil {
 		return err
 	}
 
 	// Send the post
 	embed := y.GeneratePostEmbed(post)
-	req, err := http.NewRequest(http.MethodGet, post.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.URL, nil)
 	if err != nil {
 		return err
 	}
@@ -397,7 +421,7 @@ func (y *YiffCommand) HandlePost(ctx context.Context, s *discordgo.Session, e *d
 		Reader: res.Body,
 	}
 
-	if _, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+	if _, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 		Files:  []*discordgo.File{file},
 	}); err != nil {
@@ -409,7 +433,7 @@ func (y *YiffCommand) HandlePost(ctx context.Context, s *discordgo.Session, e *d
 
 func (y *YiffCommand) HandlePopular(ctx context.Context, s *discordgo.Session, e *discordgo.InteractionCreate) error {
 	// Get the post
-	post, err := y.service.GetRandomPost()
+	post, err := y.service.GetRandomPost(ctx)
 	if err != nil {
 		return err
 	}
@@ -418,7 +442,7 @@ func (y *YiffCommand) HandlePopular(ctx context.Context, s *discordgo.Session, e
 	embed := y.GeneratePostEmbed(post)
 
 	// Create file request
-	req, err := http.NewRequest(http.MethodGet, post.URL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.URL, nil)
 	if err != nil {
 		return err
 	}
@@ -434,7 +458,7 @@ func (y *YiffCommand) HandlePopular(ctx context.Context, s *discordgo.Session, e
 		Reader: res.Body,
 	}
 
-	if _, err := s.InteractionResponseEdit(e.Interaction, &discordgo.WebhookEdit{
+	if _, err := api.GetResponder(ctx, s, e).Edit(&discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 		Files:  []*discordgo.File{file},
 	}); err != nil {
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -8,13 +9,22 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/DownloadableFox/twotto-v2/internal/api"
 	"github.com/rs/zerolog"
 )
 
 const MAX_POST_SIZE = 25 * 1024 * 1024
 
+// Autocomplete fires on every keystroke while e621 allows about 2 requests
+// per second, results are cached for a while instead
+const (
+	autocompleteTTL     = 5 * time.Minute
+	autocompleteEntries = 1024
+)
+
 type E621Post struct {
 	ID   int    `json:"id"`
 	URL  string `json:"url"`
@@ -38,17 +48,40 @@ type E621PostResponse struct {
 	} `json:"sample"`
 }
 
+type E621Tag struct {
+	ID             int     `json:"id"`
+	Name           string  `json:"name"`
+	PostCount      int     `json:"post_count"`
+	Category       int     `json:"category"`
+	AntecedentName *string `json:"antecedent_name"`
+}
+
+type E621SimilarPost struct {
+	PostID int     `json:"post_id"`
+	Score  float64 `json:"score"`
+}
+
 type IE621Service interface {
-	GetRandomPost() (*E621Post, error)
-	GetPostByID(id int) (*E621Post, error)
-	SearchPosts(tags string, limit, page int) ([]*E621Post, error)
-	GetPopularPosts() ([]*E621Post, error)
+	GetRandomPost(ctx context.Context) (*E621Post, error)
+	GetPostByID(ctx context.Context, id int) (*E621Post, error)
+	SearchPosts(ctx context.Context, tags string, limit, page int) ([]*E621Post, error)
+	GetPopularPosts(ctx context.Context) ([]*E621Post, error)
+	AutocompleteTags(ctx context.Context, query string) ([]*E621Tag, error)
+	FindSimilarPosts(ctx context.Context, imageURL string) ([]*E621SimilarPost, error)
 }
 
 type E621Service struct {
 	httpClient *http.Client
 	userAgent  string
 	logger     zerolog.Logger
+
+	lock         sync.Mutex
+	autocomplete map[string]autocompleteEntry
+}
+
+type autocompleteEntry struct {
+	tags    []*E621Tag
+	expires time.Time
 }
 
 func NewE621Service(userAgent string, parent zerolog.Logger) *E621Service {
@@ -61,15 +94,16 @@ func NewE621Service(userAgent string, parent zerolog.Logger) *E621Service {
 				},
 			},
 		},
-		userAgent: userAgent,
-		logger:    parent.With().Str("service", "e621").Logger(),
+		userAgent:    userAgent,
+		logger:       parent.With().Str("service", "e621").Logger(),
+		autocomplete: make(map[string]autocompleteEntry),
 	}
 }
 
-func (e *E621Service) GetRandomPost() (*E621Post, error) {
+func (e *E621Service) GetRandomPost(ctx context.Context) (*E621Post, error) {
 	const url = "https://e621.net/posts/random.json"
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +112,7 @@ func (e *E621Service) GetRandomPost() (*E621Post, error) {
 
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
 	}
 	defer resp.Body.Close()
 
@@ -86,16 +120,16 @@ func (e *E621Service) GetRandomPost() (*E621Post, error) {
 		Post E621PostResponse `json:"post"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&post); err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
 	}
 
-	return e.ParsePost(&post.Post)
+	return e.ParsePost(ctx, &post.Post)
 }
 
-func (e *E621Service) GetPostByID(id int) (*E621Post, error) {
+func (e *E621Service) GetPostByID(ctx context.Context, id int) (*E621Post, error) {
 	url := fmt.Sprintf("https://e621.net/posts/%d.json", id)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +138,7 @@ func (e *E621Service) GetPostByID(id int) (*E621Post, error) {
 
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
 	}
 	defer resp.Body.Close()
 
@@ -112,13 +146,13 @@ func (e *E621Service) GetPostByID(id int) (*E621Post, error) {
 		Post E621PostResponse `json:"post"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&post); err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
 	}
 
-	return e.ParsePost(&post.Post)
+	return e.ParsePost(ctx, &post.Post)
 }
 
-func (e *E621Service) SearchPosts(tags string, limit, page int) ([]*E621Post, error) {
+func (e *E621Service) SearchPosts(ctx context.Context, tags string, limit, page int) ([]*E621Post, error) {
 	// URL encode the query
 	tags = url.QueryEscape(tags)
 
@@ -128,7 +162,7 @@ func (e *E621Service) SearchPosts(tags string, limit, page int) ([]*E621Post, er
 
 	e.logger.Debug().Msgf("Search URL: %s", url)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -137,7 +171,7 @@ func (e *E621Service) SearchPosts(tags string, limit, page int) ([]*E621Post, er
 
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
 	}
 	defer resp.Body.Close()
 
@@ -146,12 +180,12 @@ func (e *E621Service) SearchPosts(tags string, limit, page int) ([]*E621Post, er
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
 	}
 
 	var result []*E621Post
 	for _, post := range posts.Posts {
-		parsed, err := e.ParsePost(post)
+		parsed, err := e.ParsePost(ctx, post)
 		if err != nil {
 			continue
 		}
@@ -167,8 +201,8 @@ type File struct {
 	URL           string
 }
 
-func (e *E621Service) GetContentLength(url string) (int, error) {
-	req, err := http.NewRequest(http.MethodHead, url, nil)
+func (e *E621Service) GetContentLength(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -186,7 +220,14 @@ func (e *E621Service) GetContentLength(url string) (int, error) {
 	}
 
 	// If the content length is not provided, we have to download the file
-	resp, err = e.httpClient.Get(url)
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("User-Agent", e.userAgent)
+
+	resp, err = e.httpClient.Do(req)
 	if err != nil {
 		return 0, err
 	}
@@ -195,13 +236,13 @@ func (e *E621Service) GetContentLength(url string) (int, error) {
 	return int(resp.ContentLength), nil
 }
 
-func (e *E621Service) FindSuitableSample(post *E621PostResponse) (string, error) {
+func (e *E621Service) FindSuitableSample(ctx context.Context, post *E621PostResponse) (string, error) {
 	useSample := post.File.Size > MAX_POST_SIZE
 	isVideo := post.File.Ext == "webm" || post.File.Ext == "mp4"
 
 	// If the post has no file URL, return an error
 	if post.File.URL == "" {
-		return "", errors.New("post is hidden for bots (requires login)")
+		return "", api.NewNotFoundError("This post is hidden for bots (requires login).")
 	}
 
 	// If sample is not required, just return the original
@@ -228,7 +269,7 @@ func (e *E621Service) FindSuitableSample(post *E621PostResponse) (string, error)
 				}
 
 				// Get the content length
-				length, err := e.GetContentLength(*url)
+				length, err := e.GetContentLength(ctx, *url)
 				if err != nil {
 					continue
 				}
@@ -274,7 +315,7 @@ func (e *E621Service) FindSuitableSample(post *E621PostResponse) (string, error)
 	}
 
 	// If the post is an image, we just return the sample
-	length, err := e.GetContentLength(post.Sample.URL)
+	length, err := e.GetContentLength(ctx, post.Sample.URL)
 	if err != nil {
 		return "", err
 	}
@@ -287,13 +328,13 @@ func (e *E621Service) FindSuitableSample(post *E621PostResponse) (string, error)
 	return post.Sample.URL, nil
 }
 
-func (e *E621Service) ParsePost(post *E621PostResponse) (*E621Post, error) {
+func (e *E621Service) ParsePost(ctx context.Context, post *E621PostResponse) (*E621Post, error) {
 	if post.ID == 0 {
-		return nil, errors.New("post was not found")
+		return nil, api.NewNotFoundError("This post was not found on e621.")
 	}
 
 	// Find the suitable sample
-	url, err := e.FindSuitableSample(post)
+	url, err := e.FindSuitableSample(ctx, post)
 	if err != nil {
 		return nil, err
 	}
@@ -311,10 +352,10 @@ func (e *E621Service) ParsePost(post *E621PostResponse) (*E621Post, error) {
 	}, nil
 }
 
-func (e *E621Service) GetPopularPosts() ([]*E621Post, error) {
+func (e *E621Service) GetPopularPosts(ctx context.Context) ([]*E621Post, error) {
 	const url = "https://e621.net/popular.json"
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -323,7 +364,7 @@ func (e *E621Service) GetPopularPosts() ([]*E621Post, error) {
 
 	resp, err := e.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
 	}
 	defer resp.Body.Close()
 
@@ -332,12 +373,12 @@ func (e *E621Service) GetPopularPosts() ([]*E621Post, error) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
-		return nil, err
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
 	}
 
 	var result []*E621Post
 	for _, post := range posts.Posts {
-		parsed, err := e.ParsePost(post)
+		parsed, err := e.ParsePost(ctx, post)
 		if err != nil {
 			continue
 		}
@@ -347,3 +388,109 @@ func (e *E621Service) GetPopularPosts() ([]*E621Post, error) {
 
 	return result, nil
 }
+
+func (e *E621Service) AutocompleteTags(ctx context.Context, query string) ([]*E621Tag, error) {
+	// e621 requires at least 3 characters to autocomplete
+	if len(query) < 3 {
+		return []*E621Tag{}, nil
+	}
+
+	query = strings.ToLower(query)
+	if tags, ok := e.cachedTags(query); ok {
+		return tags, nil
+	}
+
+	// URL encode the query
+	escaped := url.QueryEscape(query)
+
+	url := "https://e621.net/tags/autocomplete.json?search[name_matches]=%s&expiry=7"
+	url = fmt.Sprintf(url, escaped)
+
+	// Bound by the context, autocomplete results are useless after 3 seconds
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", e.userAgent)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
+	}
+	defer resp.Body.Close()
+
+	var tags []*E621Tag
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
+	}
+
+	e.cacheTags(query, tags)
+	return tags, nil
+}
+
+func (e *E621Service) cachedTags(query string) ([]*E621Tag, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	entry, exists := e.autocomplete[query]
+	if !exists || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.tags, true
+}
+
+func (e *E621Service) cacheTags(query string, tags []*E621Tag) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := time.Now()
+
+	// Drop the expired entries once the cache is full, everything if none are
+	if len(e.autocomplete) >= autocompleteEntries {
+		for key, entry := range e.autocomplete {
+			if now.After(entry.expires) {
+				delete(e.autocomplete, key)
+			}
+		}
+
+		if len(e.autocomplete) >= autocompleteEntries {
+			clear(e.autocomplete)
+		}
+	}
+
+	e.autocomplete[query] = autocompleteEntry{tags: tags, expires: now.Add(autocompleteTTL)}
+}
+
+func (e *E621Service) FindSimilarPosts(ctx context.Context, imageURL string) ([]*E621SimilarPost, error) {
+	// URL encode the image URL
+	imageURL = url.QueryEscape(imageURL)
+
+	url := "https://e621.net/iqdb_queries.json?url=%s"
+	url = fmt.Sprintf(url, imageURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", e.userAgent)
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, api.NewUnavailableError("e621 could not be reached", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, api.NewUnavailableError("e621 reverse search failed", fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+
+	var posts []*E621SimilarPost
+	if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
+		return nil, api.NewUnavailableError("e621 returned an invalid response", err)
+	}
+
+	return posts, nil
+}
@@ -30,7 +30,13 @@ func NewPopularTask(parent zerolog.Logger, service services.IE621Service) *Popul
 func (p *PopularTask) Data() api.TaskData {
 	return api.TaskData{
 		Name: "yiff-popular",
+		// Every day at 23:30, local time like it always ran
 		Cron: "30 23 * * *",
+		// Posting a missed day late beats not posting it at all
+		CatchUp: api.CatchUpOnce,
+		Overlap: api.OverlapSkip,
+		// Uploads are sequential, a stuck one shouldn't hold the task forever
+		Timeout: 30 * time.Minute,
 	}
 }
 
@@ -42,7 +48,7 @@ func (p *PopularTask) Run(ctx context.Context, s *discordgo.Session) error {
 	p.logger.Info().Msg("Fetching popular posts...")
 
 	// 1. Get all popular posts
-	posts, err := p.service.GetPopularPosts()
+	posts, err := p.service.GetPopularPosts(ctx)
 	if err != nil {
 		return err
 	}
@@ -66,7 +72,7 @@ func (p *PopularTask) Run(ctx context.Context, s *discordgo.Session) error {
 		go func() {
 			defer wg.Done()
 
-			if err := p.BeginThread(s, channels[guild.ID], posts); err != nil {
+			if err := p.BeginThread(ctx, s, channels[guild.ID], posts); err != nil {
 				p.logger.Error().Err(err).Msgf("Failed to begin thread for guild %s", guild.ID)
 			}
 		}()
@@ -79,7 +85,7 @@ func (p *PopularTask) Run(ctx context.Context, s *discordgo.Session) error {
 	return nil
 }
 
-func (p *PopularTask) BeginThread(s *discordgo.Session, channelID string, posts []*services.E621Post) error {
+func (p *PopularTask) BeginThread(ctx context.Context, s *discordgo.Session, channelID string, posts []*services.E621Post) error {
 	// 1. Send the looking for posts embed
 	startTime := time.Now()
 	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
@@ -101,7 +107,7 @@ func (p *PopularTask) BeginThread(s *discordgo.Session, channelID string, posts
 	}
 
 	// 2. Start sending posts
-	if err := p.PublishThread(s, channelID, msg.ID, posts); err != nil {
+	if err := p.PublishThread(ctx, s, channelID, msg.ID, posts); err != nil {
 		// 2.5. Send error message
 		s.ChannelMessageEditComplex(&discordgo.MessageEdit{
 			ID:      msg.ID,
@@ -166,7 +172,7 @@ func (p *PopularTask) BeginThread(s *discordgo.Session, channelID string, posts
 	return nil
 }
 
-func (y *PopularTask) PublishThread(s *discordgo.Session, channelID, messageID string, posts []*services.E621Post) error {
+func (y *PopularTask) PublishThread(ctx context.Context, s *discordgo.Session, channelID, messageID string, posts []*services.E621Post) error {
 	// Assume
 	success := true
 
@@ -182,10 +188,15 @@ func (y *PopularTask) PublishThread(s *discordgo.Session, channelID, messageID s
 
 	// Send the posts
 	for _, post := range posts {
+		// Stop uploading once the task runs out of time
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		s.ChannelTyping(thr.ID)
 
 		embed := y.GeneratePostEmbed(post)
-		req, err := http.NewRequest(http.MethodGet, post.URL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.URL, nil)
 		if err != nil {
 			y.logger.Warn().Err(err).Msgf("Failed to create request for post #%d (source: %s)", post.ID, post.URL)
 			success = false
@@ -1,8 +1,11 @@
 package yiff
 
 import (
+	"time"
+
 	"github.com/DownloadableFox/twotto-v2/internal/api"
-	"github.com/DownloadableFox/twotto-v2/internal/modules/core/middlewares"
+	"github.com/DownloadableFox/twotto-v2/internal/config"
+	"github.com/DownloadableFox/twotto-v2/internal/middlewares"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/yiff/commands"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/yiff/services"
 	"github.com/DownloadableFox/twotto-v2/internal/modules/yiff/tasks"
@@ -13,14 +16,16 @@ var _ api.Module = (*YiffModule)(nil)
 
 type YiffModule struct {
 	logger  zerolog.Logger
+	config  config.Config
 	service services.IE621Service
 }
 
-func NewYiffModule(parent zerolog.Logger) *YiffModule {
+func NewYiffModule(parent zerolog.Logger, config config.Config) *YiffModule {
 	logger := parent.With().Str("module", "yiff").Logger()
 
 	return &YiffModule{
 		logger:  logger,
+		config:  config,
 		service: services.NewE621Service("twotto-v2", logger),
 	}
 }
@@ -33,11 +38,23 @@ func (m *YiffModule) Commands() ([]api.CommandStack, error) {
 	return []api.CommandStack{
 		api.CompileCommand(
 			commands.NewYiffCommand(m.service, m.logger),
-			middlewares.NewRecoverMiddleware(m.logger),
+			middlewares.NewCooldownMiddleware(m.logger, m.config.Owners, middlewares.CooldownUser, 3, time.Minute),
+			middlewares.NewConcurrencyMiddleware(m.logger, 4, 1, true),
+		),
+		api.CompileMessageCommand(
+			commands.NewSourceCommand(m.service, m.logger),
 		),
 	}, nil
 }
 
+func (m *YiffModule) Components() ([]api.ComponentStack, error) {
+	return []api.ComponentStack{}, nil
+}
+
+func (m *YiffModule) JobHandlers() (map[string]api.JobHandler, error) {
+	return map[string]api.JobHandler{}, nil
+}
+
 func (m *YiffModule) Tasks() ([]api.TaskStack, error) {
 	return []api.TaskStack{
 		api.CompileTasks(
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+var _ api.CommandGuildStore = (*CommandGuildStore)(nil)
+
+var (
+	commandsBucket     = []byte("commands")
+	publishedGuildsKey = []byte("published-guilds")
+)
+
+// CommandGuildStore keeps the guilds commands were last published to.
+type CommandGuildStore struct {
+	db *bbolt.DB
+}
+
+func NewCommandGuildStore(db *bbolt.DB) (*CommandGuildStore, error) {
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(commandsBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &CommandGuildStore{db: db}, nil
+}
+
+func (s *CommandGuildStore) PublishedGuilds() ([]string, error) {
+	var guildIDs []string
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(commandsBucket).Get(publishedGuildsKey)
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &guildIDs)
+	})
+
+	return guildIDs, err
+}
+
+func (s *CommandGuildStore) SavePublishedGuilds(guildIDs []string) error {
+	data, err := json.Marshal(guildIDs)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(commandsBucket).Put(publishedGuildsKey, data)
+	})
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"github.com/rs/xid"
+	"go.etcd.io/bbolt"
+)
+
+var _ api.ErrorReportStore = (*ErrorReportStore)(nil)
+var _ api.ErrorSink = (*ErrorReportStore)(nil)
+
+var errorReportsBucket = []byte("error-reports")
+
+// Reports kept by default, a crash loop would grow the database forever
+const (
+	DefaultErrorReportMaxAge   = 30 * 24 * time.Hour
+	DefaultErrorReportMaxCount = 1000
+)
+
+// ErrorReportStore keeps error reports keyed by their ID, since IDs are
+// time ordered the bucket is sorted from oldest to newest.
+type ErrorReportStore struct {
+	// Older reports are pruned when saving, no limit when zero
+	MaxAge   time.Duration
+	MaxCount int
+
+	db *bbolt.DB
+}
+
+func NewErrorReportStore(db *bbolt.DB) (*ErrorReportStore, error) {
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(errorReportsBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &ErrorReportStore{
+		MaxAge:   DefaultErrorReportMaxAge,
+		MaxCount: DefaultErrorReportMaxCount,
+		db:       db,
+	}, nil
+}
+
+func (s *ErrorReportStore) Save(report *api.ErrorReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(errorReportsBucket)
+		if err := bucket.Put(report.ID.Bytes(), data); err != nil {
+			return err
+		}
+
+		return s.prune(bucket)
+	})
+}
+
+// Deletes the oldest reports past the maximum age or count.
+func (s *ErrorReportStore) prune(bucket *bbolt.Bucket) error {
+	var keys [][]byte
+	if err := bucket.ForEach(func(key, _ []byte) error {
+		keys = append(keys, slices.Clone(key))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	cutoff := time.Time{}
+	if s.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.MaxAge)
+	}
+
+	for index, key := range keys {
+		id, err := xid.FromBytes(key)
+		if err != nil {
+			return err
+		}
+
+		// Keys are sorted by time, the first one young enough ends it
+		overflow := s.MaxCount > 0 && len(keys)-index > s.MaxCount
+		if !overflow && !id.Time().Before(cutoff) {
+			break
+		}
+
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ErrorReportStore) Report(c context.Context, report *api.ErrorReport) {
+	if err := s.Save(report); err != nil {
+		api.Logger(c).Warn().Err(err).Str("error_id", report.ID.String()).Msg("Failed to save error report!")
+	}
+}
+
+func (s *ErrorReportStore) Get(id xid.ID) (*api.ErrorReport, error) {
+	var report *api.ErrorReport
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(errorReportsBucket).Get(id.Bytes())
+		if data == nil {
+			return api.ErrReportNotFound
+		}
+
+		return json.Unmarshal(data, &report)
+	})
+
+	return report, err
+}
+
+func (s *ErrorReportStore) Recent(limit int) ([]*api.ErrorReport, error) {
+	reports := make([]*api.ErrorReport, 0, limit)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(errorReportsBucket).Cursor()
+
+		for key, data := cursor.Last(); key != nil && len(reports) < limit; key, data = cursor.Prev() {
+			var report *api.ErrorReport
+			if err := json.Unmarshal(data, &report); err != nil {
+				return err
+			}
+
+			reports = append(reports, report)
+		}
+
+		return nil
+	})
+
+	return reports, err
+}
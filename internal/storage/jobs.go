@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+var _ api.JobStore = (*JobStore)(nil)
+
+var jobsBucket = []byte("jobs")
+
+// JobStore keeps persisted jobs keyed by their name.
+type JobStore struct {
+	db *bbolt.DB
+}
+
+func NewJobStore(db *bbolt.DB) (*JobStore, error) {
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(jobsBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &JobStore{db: db}, nil
+}
+
+func (s *JobStore) SaveJob(job api.Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).Put([]byte(job.Name), data)
+	})
+}
+
+func (s *JobStore) DeleteJob(name string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).Delete([]byte(name))
+	})
+}
+
+func (s *JobStore) LoadJobs() ([]api.Job, error) {
+	var jobs []api.Job
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(key, data []byte) error {
+			var job api.Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return err
+			}
+
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+
+	return jobs, err
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Opens the embedded database at the given path, creating it when missing.
+func Open(path string) (*bbolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/DownloadableFox/twotto-v2/internal/api"
+	"go.etcd.io/bbolt"
+)
+
+var _ api.TaskHistoryStore = (*TaskHistoryStore)(nil)
+
+var taskHistoryBucket = []byte("task-history")
+
+// TaskHistoryStore keeps the record of each task keyed by its name.
+type TaskHistoryStore struct {
+	db *bbolt.DB
+}
+
+func NewTaskHistoryStore(db *bbolt.DB) (*TaskHistoryStore, error) {
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(taskHistoryBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &TaskHistoryStore{db: db}, nil
+}
+
+func (s *TaskHistoryStore) Record(name string, run api.TaskRun) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(taskHistoryBucket)
+
+		record, err := getTaskRecord(bucket, name)
+		if err != nil {
+			return err
+		}
+
+		record.LastRun = &run
+		if run.Outcome == api.OutcomeSuccess {
+			record.LastSuccess = &run
+		}
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(name), data)
+	})
+}
+
+func (s *TaskHistoryStore) Get(name string) (*api.TaskRecord, error) {
+	var record *api.TaskRecord
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		record, err = getTaskRecord(tx.Bucket(taskHistoryBucket), name)
+		return err
+	})
+
+	return record, err
+}
+
+func getTaskRecord(bucket *bbolt.Bucket, name string) (*api.TaskRecord, error) {
+	record := &api.TaskRecord{Name: name}
+
+	data := bucket.Get([]byte(name))
+	if data == nil {
+		return record, nil
+	}
+
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}